@@ -0,0 +1,155 @@
+package control
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+// socketName is the name of the control socket, in the directory of the config file
+const socketName = ".treli.sock"
+
+// Socket returns the path of the control socket of the project in dir
+func Socket(dir string) string {
+	return filepath.Join(dir, socketName)
+}
+
+// Proc is a proc and what it's doing
+type Proc struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Kind    string    `json:"kind"`
+	State   string    `json:"state"`
+	Active  bool      `json:"active"`
+	LastRun *Run      `json:"last_run,omitempty"`
+}
+
+// Run is a finished run of a proc
+type Run struct {
+	ID       int           `json:"id"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	ExitCode int           `json:"exit_code"`
+	Signal   string        `json:"signal,omitempty"`
+	Trigger  string        `json:"trigger"`
+	Variant  string        `json:"variant"`
+}
+
+// Line is a line of output, Index is its position in the logs of its proc
+type Line struct {
+	Index   int       `json:"index"`
+	Time    time.Time `json:"time"`
+	Stream  string    `json:"stream"`
+	Text    string    `json:"text"`
+	Run     int       `json:"run"`
+	Partial bool      `json:"partial,omitempty"`
+}
+
+// Event is an event of a proc, or of the config file when Proc is empty. Fields that don't apply to its type are empty
+type Event struct {
+	Type string `json:"type"`
+	Proc string `json:"proc"`
+
+	// log
+	Line *Line `json:"line,omitempty"`
+
+	// state
+	State string `json:"state,omitempty"`
+
+	// triggered
+	RunID   int    `json:"run_id,omitempty"`
+	Trigger string `json:"trigger,omitempty"`
+	Variant string `json:"variant,omitempty"`
+
+	// finished
+	Run *Run `json:"run,omitempty"`
+
+	// config, why the changed config is invalid
+	Error string `json:"error,omitempty"`
+}
+
+// Types of events
+const (
+	EventLog       = "log"
+	EventState     = "state"
+	EventTriggered = "triggered"
+	EventFinished  = "finished"
+	EventConfig    = "config"
+)
+
+// Error is the body of a response that failed
+type Error struct {
+	Error string `json:"error"`
+}
+
+func newProc(p *proc.Proc) Proc {
+	info := Proc{
+		ID:     p.ID,
+		Name:   p.Name,
+		Kind:   string(p.Kind),
+		State:  p.State().String(),
+		Active: p.Active(),
+	}
+
+	if history := p.History(); len(history) > 0 {
+		run := newRun(history[len(history)-1])
+		info.LastRun = &run
+	}
+
+	return info
+}
+
+func newRun(r proc.Run) Run {
+	return Run{
+		ID:       r.ID,
+		Start:    r.Start,
+		End:      r.End,
+		Duration: r.Duration,
+		ExitCode: r.ExitCode,
+		Signal:   r.Signal,
+		Trigger:  r.Trigger.String(),
+		Variant:  string(r.Variant),
+	}
+}
+
+func newLine(index int, l proc.Line) Line {
+	return Line{
+		Index:   index,
+		Time:    l.Time,
+		Stream:  string(l.Stream),
+		Text:    l.Text,
+		Run:     l.Run,
+		Partial: l.Partial,
+	}
+}
+
+// newEvent converts e, returns false for events that aren't sent
+func newEvent(name string, e proc.Event) (Event, bool) {
+	switch e := e.(type) {
+	case proc.LogAppended:
+		line := newLine(e.Index, e.Line)
+		return Event{Type: EventLog, Proc: name, Line: &line}, true
+
+	case proc.StateChanged:
+		return Event{Type: EventState, Proc: name, State: e.State.String()}, true
+
+	case proc.Triggered:
+		return Event{Type: EventTriggered, Proc: name, RunID: e.Run, Trigger: e.Trigger.String(), Variant: string(e.Variant)}, true
+
+	case proc.RunFinished:
+		run := newRun(e.Run)
+		return Event{Type: EventFinished, Proc: name, Run: &run}, true
+
+	case proc.ConfigReloaded:
+		event := Event{Type: EventConfig}
+		if e.Err != nil {
+			event.Error = e.Err.Error()
+		}
+		return event, true
+	}
+
+	return Event{}, false
+}
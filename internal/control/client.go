@@ -0,0 +1,179 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxEvent is the most bytes an event can have
+const maxEvent = 16 * 1024 * 1024
+
+// ErrNotRunning is returned when there's no treli serving the socket
+var ErrNotRunning = errors.New("treli is not running")
+
+// Client talks to a running treli over its control socket
+type Client struct {
+	http *http.Client
+}
+
+func NewClient(socket string) *Client {
+	dialer := &net.Dialer{}
+
+	return &Client{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					conn, err := dialer.DialContext(ctx, "unix", socket)
+					if err != nil {
+						return nil, fmt.Errorf("%w: %w", ErrNotRunning, err)
+					}
+
+					return conn, nil
+				},
+			},
+		},
+	}
+}
+
+// Procs returns every proc
+func (c *Client) Procs(ctx context.Context) ([]Proc, error) {
+	procs := []Proc{}
+	err := c.do(ctx, http.MethodGet, "/procs", &procs)
+
+	return procs, err
+}
+
+// Start starts a proc without waiting for it to exit
+func (c *Client) Start(ctx context.Context, name string) (Proc, error) {
+	p := Proc{}
+	err := c.do(ctx, http.MethodPost, "/procs/"+url.PathEscape(name)+"/start", &p)
+
+	return p, err
+}
+
+// Stop stops a proc, waiting for it to exit
+func (c *Client) Stop(ctx context.Context, name string) (Proc, error) {
+	p := Proc{}
+	err := c.do(ctx, http.MethodPost, "/procs/"+url.PathEscape(name)+"/stop", &p)
+
+	return p, err
+}
+
+// Restart stops a proc if it's running and starts it again, without waiting for it to exit
+func (c *Client) Restart(ctx context.Context, name string) (Proc, error) {
+	p := Proc{}
+	err := c.do(ctx, http.MethodPost, "/procs/"+url.PathEscape(name)+"/restart", &p)
+
+	return p, err
+}
+
+// Logs returns the last n lines of a proc, every line with n 0
+func (c *Client) Logs(ctx context.Context, name string, n int) ([]Line, error) {
+	lines := []Line{}
+	err := c.do(ctx, http.MethodGet, "/procs/"+url.PathEscape(name)+"/logs?n="+strconv.Itoa(n), &lines)
+
+	return lines, err
+}
+
+// Events streams the events of every proc, or only those of name if it isn't empty.
+// Events published after Events returns are in the stream
+func (c *Client) Events(ctx context.Context, name string) (*Events, error) {
+	path := "/events"
+	if name != "" {
+		path += "?proc=" + url.QueryEscape(name)
+	}
+
+	res, err := c.request(ctx, http.MethodGet, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines of output can be longer than the default limit of a token
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxEvent)
+
+	return &Events{
+		body:    res.Body,
+		scanner: scanner,
+	}, nil
+}
+
+// Events is a stream of events
+type Events struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// Next waits for the next event, returns io.EOF once the stream ends
+func (e *Events) Next() (Event, error) {
+	data := ""
+	for e.scanner.Scan() {
+		line := e.scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "data: "):
+			data += strings.TrimPrefix(line, "data: ")
+
+		case line == "" && data != "":
+			event := Event{}
+			err := json.Unmarshal([]byte(data), &event)
+
+			return event, err
+		}
+	}
+	if err := e.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+
+	return Event{}, io.EOF
+}
+
+func (e *Events) Close() error {
+	return e.body.Close()
+}
+
+// do sends a request and decodes the response into v
+func (c *Client) do(ctx context.Context, method string, path string, v any) error {
+	res, err := c.request(ctx, method, path)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// request sends a request, turning responses that failed into errors
+func (c *Client) request(ctx context.Context, method string, path string) (*http.Response, error) {
+	// The host is ignored, every request goes to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://treli"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 400 {
+		defer res.Body.Close()
+
+		e := Error{}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error == "" {
+			return nil, fmt.Errorf("%s %s: %s", method, path, res.Status)
+		}
+
+		return nil, errors.New(e.Error)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,55 @@
+// Package controltest serves procs over a control socket, like a running treli, for tests
+package controltest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spotdemo4/treli/internal/control"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+// Proc returns a proc of kind running onstart in a temporary directory
+func Proc(t testing.TB, bus *proc.Bus, name string, kind proc.Kind, onstart string) *proc.Proc {
+	t.Helper()
+
+	return proc.New(name, proc.Options{Kind: kind, OnStart: onstart, Dir: t.TempDir()}, bus)
+}
+
+// Serve serves procs on a socket in a temporary directory, returning a client for it.
+// Procs are stopped, and the server and bus are closed once the test ends
+func Serve(t testing.TB, bus *proc.Bus, procs ...*proc.Proc) *control.Client {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	socket := control.Socket(t.TempDir())
+	stop, err := control.NewServer(ctx, procs, bus).Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		stop()
+		cancel()
+		for _, p := range procs {
+			p.Stop()
+		}
+		bus.Close()
+	})
+
+	return control.NewClient(socket)
+}
+
+// Eventually fails the test if cond isn't true within a few seconds
+func Eventually(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
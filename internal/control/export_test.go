@@ -0,0 +1,4 @@
+package control
+
+// WriteTimeout is writeTimeout for the tests of control_test
+const WriteTimeout = writeTimeout
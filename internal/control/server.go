@@ -0,0 +1,274 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+// writeTimeout is how long a client has to read an event before its stream is closed
+const writeTimeout = 5 * time.Second
+
+// queuedEvents is how many events a stream queues before it coalesces them
+const queuedEvents = 1000
+
+// Server controls procs over HTTP on a unix socket, using the same methods as the TUI
+type Server struct {
+	ctx   context.Context
+	procs []*proc.Proc
+	bus   *proc.Bus
+	names map[uuid.UUID]string
+}
+
+func NewServer(ctx context.Context, procs []*proc.Proc, bus *proc.Bus) *Server {
+	s := &Server{
+		ctx:   ctx,
+		procs: procs,
+		bus:   bus,
+		names: map[uuid.UUID]string{},
+	}
+	for _, p := range procs {
+		s.names[p.ID] = p.Name
+	}
+
+	return s
+}
+
+// Listen serves on a unix socket at path until stop is called, which removes the socket.
+// A socket left behind by treli exiting early is replaced, one that's still served isn't
+func (s *Server) Listen(path string) (stop func(), err error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("treli is already running, %s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// stop removes the socket, closing can happen later and would remove the socket of the next server at path
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	srv := &http.Server{Handler: s.Handler()}
+	go srv.Serve(l)
+
+	return func() {
+		// Close instead of shutting down, event streams don't end by themselves
+		srv.Close()
+		os.Remove(path)
+	}, nil
+}
+
+// Handler returns the routes of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /procs", s.list)
+	mux.HandleFunc("GET /procs/{name}", s.get)
+	mux.HandleFunc("POST /procs/{name}/start", s.start)
+	mux.HandleFunc("POST /procs/{name}/stop", s.stop)
+	mux.HandleFunc("POST /procs/{name}/restart", s.restart)
+	mux.HandleFunc("GET /procs/{name}/logs", s.logs)
+	mux.HandleFunc("GET /events", s.events)
+
+	return mux
+}
+
+// list responds with every proc
+func (s *Server) list(w http.ResponseWriter, r *http.Request) {
+	procs := []Proc{}
+	for _, p := range s.procs {
+		procs = append(procs, newProc(p))
+	}
+
+	respond(w, http.StatusOK, procs)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+
+	respond(w, http.StatusOK, newProc(p))
+}
+
+// start starts a proc without waiting for it to exit
+func (s *Server) start(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	if p.Active() {
+		fail(w, http.StatusConflict, proc.ErrStarted)
+		return
+	}
+
+	go p.Start(s.ctx, proc.TriggerManual)
+
+	respond(w, http.StatusAccepted, newProc(p))
+}
+
+// stop stops a proc, waiting for it to exit
+func (s *Server) stop(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+
+	if err := p.Stop(); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, proc.ErrNotStarted) {
+			status = http.StatusConflict
+		}
+
+		fail(w, status, err)
+		return
+	}
+
+	respond(w, http.StatusOK, newProc(p))
+}
+
+// restart stops a proc if it's running and starts it again, without waiting for it to exit
+func (s *Server) restart(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+
+	go p.Rerun(s.ctx)
+
+	respond(w, http.StatusAccepted, newProc(p))
+}
+
+// logs responds with the last n lines of a proc, 100 by default and every line with n=0
+func (s *Server) logs(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+
+	n := 100
+	if q := r.URL.Query().Get("n"); q != "" {
+		var err error
+		n, err = strconv.Atoi(q)
+		if err != nil || n < 0 {
+			fail(w, http.StatusBadRequest, fmt.Errorf("n must be a number of lines, not %s", q))
+			return
+		}
+	}
+
+	lines := p.LogsSince(0)
+	from := 0
+	if n > 0 {
+		from = max(len(lines)-n, 0)
+	}
+
+	res := []Line{}
+	for i, l := range lines[from:] {
+		res = append(res, newLine(from+i, l))
+	}
+
+	respond(w, http.StatusOK, res)
+}
+
+// events streams the events of every proc, or only those of proc, as server-sent events
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("proc")
+	if name != "" && s.lookup(name) == nil {
+		fail(w, http.StatusNotFound, fmt.Errorf("proc %s not found", name))
+		return
+	}
+
+	// Subscribe before responding, so nothing is missed once the client has the headers.
+	// A client that can't keep up mustn't make procs wait, so it loses log events instead, which leaves gaps in their indexes
+	events := s.bus.Subscribe(proc.Coalesce, queuedEvents)
+	defer events.Close()
+	go func() {
+		<-r.Context().Done()
+		events.Close()
+	}()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	for {
+		e, ok := events.Next()
+		if !ok {
+			return
+		}
+
+		pname := s.names[e.ProcID()]
+		if name != "" && pname != name {
+			continue
+		}
+		event, ok := newEvent(pname, e)
+		if !ok {
+			continue
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+
+		rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// find returns the proc named in the path, responding with an error if there isn't one
+func (s *Server) find(w http.ResponseWriter, r *http.Request) (*proc.Proc, bool) {
+	name := r.PathValue("name")
+	p := s.lookup(name)
+	if p == nil {
+		fail(w, http.StatusNotFound, fmt.Errorf("proc %s not found", name))
+		return nil, false
+	}
+
+	return p, true
+}
+
+func (s *Server) lookup(name string) *proc.Proc {
+	for _, p := range s.procs {
+		if p.Name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+func respond(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func fail(w http.ResponseWriter, status int, err error) {
+	respond(w, status, Error{Error: err.Error()})
+}
@@ -0,0 +1,338 @@
+package control_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/spotdemo4/treli/internal/control"
+	"github.com/spotdemo4/treli/internal/control/controltest"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+func TestServerProcs(t *testing.T) {
+	bus := proc.NewBus()
+	ran := controltest.Proc(t, bus, "ran", proc.KindTask, "exit 2")
+	idle := controltest.Proc(t, bus, "idle", proc.KindService, "echo")
+	ran.Start(context.Background(), proc.TriggerManual)
+
+	c := controltest.Serve(t, bus, ran, idle)
+
+	procs, err := c.Procs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(procs) != 2 {
+		t.Fatalf("Procs() = %+v, want 2", procs)
+	}
+
+	got, want := procs[0], control.Proc{ID: ran.ID, Name: "ran", Kind: "task", State: "error"}
+	if got.LastRun == nil || got.LastRun.ExitCode != 2 || got.LastRun.Variant != "onstart" {
+		t.Errorf("LastRun = %+v, want onstart that exited with 2", got.LastRun)
+	}
+	got.LastRun = nil
+	if got != want {
+		t.Errorf("Procs()[0] = %+v, want %+v", got, want)
+	}
+
+	if got, want := procs[1], (control.Proc{ID: idle.ID, Name: "idle", Kind: "service", State: "idle"}); got != want {
+		t.Errorf("Procs()[1] = %+v, want %+v", got, want)
+	}
+}
+
+func TestServerStartStop(t *testing.T) {
+	bus := proc.NewBus()
+	p := controltest.Proc(t, bus, "app", proc.KindService, "exec sleep 60")
+	c := controltest.Serve(t, bus, p)
+	ctx := context.Background()
+
+	if _, err := c.Stop(ctx, "app"); err == nil {
+		t.Error("Stop() succeeded before the proc was started")
+	}
+
+	res, err := c.Start(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Name != "app" {
+		t.Errorf("Start() = %+v, want app", res)
+	}
+	controltest.Eventually(t, "app to run", func() bool { return p.State() == proc.StateRunning })
+
+	if _, err := c.Start(ctx, "app"); err == nil {
+		t.Error("Start() succeeded while the proc was running")
+	}
+
+	// Stopping waits for the proc to exit
+	res, err = c.Stop(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Active || p.Active() {
+		t.Errorf("Stop() = %+v, want it not active", res)
+	}
+
+	for _, fn := range []func(context.Context, string) (control.Proc, error){c.Start, c.Stop, c.Restart} {
+		if _, err := fn(ctx, "missing"); err == nil || err.Error() != "proc missing not found" {
+			t.Errorf("err = %v for a missing proc, want not found", err)
+		}
+	}
+}
+
+func TestServerRestart(t *testing.T) {
+	bus := proc.NewBus()
+	task := controltest.Proc(t, bus, "task", proc.KindTask, "echo done")
+	service := controltest.Proc(t, bus, "service", proc.KindService, "exec sleep 60")
+	c := controltest.Serve(t, bus, task, service)
+	ctx := context.Background()
+
+	// A proc that isn't running is started
+	if _, err := c.Restart(ctx, "task"); err != nil {
+		t.Fatal(err)
+	}
+	controltest.Eventually(t, "task to run once", func() bool { return len(task.History()) == 1 })
+
+	// A running one is stopped first
+	go service.Start(ctx, proc.TriggerManual)
+	controltest.Eventually(t, "service to run", func() bool { return service.State() == proc.StateRunning })
+	if _, err := c.Restart(ctx, "service"); err != nil {
+		t.Fatal(err)
+	}
+	controltest.Eventually(t, "service to run again", func() bool {
+		return len(service.History()) == 1 && service.State() == proc.StateRunning
+	})
+
+	if run := service.History()[0]; run.Signal == "" {
+		t.Errorf("first run = %+v, want it stopped by a signal", run)
+	}
+}
+
+func TestServerLogs(t *testing.T) {
+	bus := proc.NewBus()
+	p := controltest.Proc(t, bus, "app", proc.KindTask, "seq 5; sleep 0.1; echo oops >&2")
+	p.Start(context.Background(), proc.TriggerManual)
+	c := controltest.Serve(t, bus, p)
+
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{n: 0, want: []string{"1", "2", "3", "4", "5", "oops"}},
+		{n: 2, want: []string{"5", "oops"}},
+		{n: 100, want: []string{"1", "2", "3", "4", "5", "oops"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.n), func(t *testing.T) {
+			lines, err := c.Logs(context.Background(), "app", tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			text := []string{}
+			for i, l := range lines {
+				text = append(text, l.Text)
+
+				// Indexes are positions in every line of the proc
+				if want := len(p.Logs()) - len(lines) + i; l.Index != want {
+					t.Errorf("line %q has index %d, want %d", l.Text, l.Index, want)
+				}
+				if l.Run != 1 {
+					t.Errorf("line %q is of run %d, want 1", l.Text, l.Run)
+				}
+			}
+			if !slices.Equal(text, tt.want) {
+				t.Errorf("Logs() = %q, want %q", text, tt.want)
+			}
+		})
+	}
+
+	if _, err := c.Logs(context.Background(), "missing", 0); err == nil {
+		t.Error("Logs() succeeded for a missing proc")
+	}
+}
+
+func TestServerEvents(t *testing.T) {
+	bus := proc.NewBus()
+	app := controltest.Proc(t, bus, "app", proc.KindTask, "echo hello")
+	other := controltest.Proc(t, bus, "other", proc.KindTask, "echo other")
+	c := controltest.Serve(t, bus, app, other)
+	ctx := context.Background()
+
+	events, err := c.Events(ctx, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+
+	other.Start(ctx, proc.TriggerManual)
+	app.Start(ctx, proc.TriggerManual)
+
+	// Read until the run of app finished
+	got := []control.Event{}
+	for {
+		e, err := events.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Proc != "app" {
+			t.Fatalf("got event %+v of another proc", e)
+		}
+
+		got = append(got, e)
+		if e.Type == control.EventFinished {
+			break
+		}
+	}
+
+	types := []string{}
+	for _, e := range got {
+		types = append(types, e.Type)
+
+		switch e.Type {
+		case control.EventTriggered:
+			if e.RunID != 1 || e.Trigger != "manual" || e.Variant != "onstart" {
+				t.Errorf("triggered = %+v, want run 1 started manually", e)
+			}
+		case control.EventLog:
+			if e.Line.Text != "hello" || e.Line.Stream != "out" || e.Line.Index != 0 {
+				t.Errorf("log = %+v, want hello", e.Line)
+			}
+		case control.EventFinished:
+			if e.Run.ID != 1 || e.Run.ExitCode != 0 {
+				t.Errorf("finished = %+v, want run 1 that exited with 0", e.Run)
+			}
+		}
+	}
+	for _, want := range []string{control.EventTriggered, control.EventState, control.EventLog, control.EventFinished} {
+		if !slices.Contains(types, want) {
+			t.Errorf("events = %v, want a %s event", types, want)
+		}
+	}
+}
+
+func TestServerEventsConfig(t *testing.T) {
+	bus := proc.NewBus()
+	c := controltest.Serve(t, bus, controltest.Proc(t, bus, "app", proc.KindTask, "echo"))
+
+	events, err := c.Events(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+
+	bus.Publish(proc.ConfigReloaded{File: "treli.yaml", Err: errors.New("bad")})
+
+	e, err := events.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (control.Event{Type: control.EventConfig, Error: "bad"}); e != want {
+		t.Errorf("event = %+v, want %+v", e, want)
+	}
+}
+
+// A client that stops reading mustn't make procs wait for it
+func TestServerEventsStuck(t *testing.T) {
+	bus := proc.NewBus()
+	p := controltest.Proc(t, bus, "app", proc.KindTask, "seq 50000")
+	c := controltest.Serve(t, bus, p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.Events(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Start(context.Background(), proc.TriggerManual)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(control.WriteTimeout - time.Second):
+		t.Fatal("proc is waiting for a client that isn't reading")
+	}
+
+	// What was queued can still be read, with gaps in the indexes where events were coalesced
+	next := 0
+	gaps := false
+	for {
+		e, err := events.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Type == control.EventFinished {
+			break
+		}
+		if e.Type != control.EventLog {
+			continue
+		}
+
+		if e.Line.Index != next {
+			gaps = true
+		}
+		next = e.Line.Index + 1
+	}
+	if !gaps {
+		t.Error("every line was sent, want some coalesced")
+	}
+}
+
+func TestServerListen(t *testing.T) {
+	bus := proc.NewBus()
+	defer bus.Close()
+
+	dir := t.TempDir()
+	socket := control.Socket(dir)
+	s := control.NewServer(context.Background(), nil, bus)
+
+	stop, err := s.Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The socket is in use
+	if _, err := s.Listen(socket); err == nil {
+		t.Error("Listen() succeeded on a socket that's served")
+	}
+
+	stop()
+	if _, err := os.Stat(socket); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket wasn't removed: %v", err)
+	}
+
+	// One left behind is replaced
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	stop, err = s.Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen() = %v on a socket left behind", err)
+	}
+	defer stop()
+
+	procs, err := control.NewClient(socket).Procs(context.Background())
+	if err != nil || len(procs) != 0 {
+		t.Errorf("Procs() = %v, %v, want none", procs, err)
+	}
+
+	// Nothing serving
+	stop()
+	if _, err := control.NewClient(socket).Procs(context.Background()); !errors.Is(err, control.ErrNotRunning) {
+		t.Errorf("Procs() = %v after stopping, want %v", err, control.ErrNotRunning)
+	}
+}
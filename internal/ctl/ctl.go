@@ -0,0 +1,266 @@
+package ctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spotdemo4/treli/internal/control"
+	"github.com/spotdemo4/treli/internal/logs"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+// Action is what to do to procs of a running treli
+type Action string
+
+const (
+	ActionStart   Action = "start"
+	ActionStop    Action = "stop"
+	ActionRestart Action = "restart"
+)
+
+// Status prints the state of every proc of a running treli.
+// Returns the exit code
+func Status(ctx context.Context, c *control.Client, out io.Writer) int {
+	procs, err := c.Procs(ctx)
+	if err != nil {
+		fmt.Fprintf(out, "Could not get status: %v\n", err)
+		return 1
+	}
+
+	rows := [][]string{}
+	for _, p := range procs {
+		last := ""
+		if p.LastRun != nil {
+			last = fmt.Sprintf("run %d %s", p.LastRun.ID, exit(*p.LastRun))
+			last += fmt.Sprintf(" in %s, %s ago", p.LastRun.Duration.Round(time.Millisecond), time.Since(p.LastRun.End).Round(time.Second))
+		}
+
+		rows = append(rows, []string{p.Name, p.Kind, p.State, last})
+	}
+
+	r := lipgloss.NewRenderer(out)
+	header := r.NewStyle().Bold(true).Padding(0, 1)
+	cell := r.NewStyle().Padding(0, 1)
+	fmt.Fprintln(out, table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(r.NewStyle().Foreground(lipgloss.Color("#45475a"))).
+		Headers("proc", "kind", "state", "last run").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return header
+			}
+			return cell
+		}).
+		String())
+
+	return 0
+}
+
+// exit returns how a run exited
+func exit(run control.Run) string {
+	if run.Signal != "" {
+		return "killed by " + run.Signal
+	}
+
+	return fmt.Sprintf("exited with %d", run.ExitCode)
+}
+
+// Control starts, stops or restarts the procs named of a running treli, or every proc with all.
+// Returns the exit code, which is 1 if doing it to any proc failed
+func Control(ctx context.Context, c *control.Client, action Action, names []string, all bool, out io.Writer) int {
+	if all {
+		procs, err := c.Procs(ctx)
+		if err != nil {
+			fmt.Fprintf(out, "Could not %s: %v\n", action, err)
+			return 1
+		}
+
+		names = []string{}
+		for _, p := range procs {
+			// Only act on procs where it makes sense, so doing it to all of them isn't an error
+			switch {
+			case action == ActionStart && p.Active:
+			case action == ActionStop && !p.Active:
+			default:
+				names = append(names, p.Name)
+			}
+		}
+	}
+
+	code := 0
+	for _, name := range names {
+		var err error
+		switch action {
+		case ActionStart:
+			_, err = c.Start(ctx, name)
+		case ActionStop:
+			_, err = c.Stop(ctx, name)
+		case ActionRestart:
+			_, err = c.Restart(ctx, name)
+		}
+
+		if err != nil {
+			fmt.Fprintf(out, "Could not %s %s: %v\n", action, name, err)
+			code = 1
+			continue
+		}
+
+		fmt.Fprintf(out, "%s %s\n", name, done[action])
+	}
+
+	return code
+}
+
+var done = map[Action]string{
+	ActionStart:   "started",
+	ActionStop:    "stopped",
+	ActionRestart: "restarted",
+}
+
+// Logs prints the output of name from a running treli, or of every proc with logs.All,
+// keeping on printing new output with follow until ctx is done.
+// If treli isn't running, it reads the logs written to logDir instead.
+// Returns the exit code
+func Logs(ctx context.Context, c *control.Client, logDir string, name string, follow bool, out io.Writer) int {
+	err := stream(ctx, c, name, follow, out)
+	switch {
+	case errors.Is(err, control.ErrNotRunning) && logDir != "":
+		err = logs.Read(ctx, logDir, name, follow, out)
+	case errors.Is(err, control.ErrNotRunning):
+		err = fmt.Errorf("%w and log_dir is not set in the config file", control.ErrNotRunning)
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Could not read logs: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// stream prints the output of a running treli in the same format as the log files
+func stream(ctx context.Context, c *control.Client, name string, follow bool, out io.Writer) error {
+	procs, err := c.Procs(ctx)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	names := []string{}
+	for _, p := range procs {
+		width = max(width, len(p.Name))
+		if name == logs.All || p.Name == name {
+			names = append(names, p.Name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("proc %s not found", name)
+	}
+
+	write := func(pname string, line control.Line) {
+		text := logs.Format(proc.Line{
+			Time:   line.Time,
+			Text:   line.Text,
+			Stream: proc.Stream(line.Stream),
+		})
+		if name == logs.All {
+			text = fmt.Sprintf("%-*s %s", width, pname, text)
+		}
+
+		fmt.Fprint(out, text)
+	}
+
+	// Follow before reading what's there, so nothing is missed in between
+	var events *control.Events
+	if follow {
+		filter := name
+		if name == logs.All {
+			filter = ""
+		}
+
+		events, err = c.Events(ctx, filter)
+		if err != nil {
+			return err
+		}
+		defer events.Close()
+	}
+
+	type entry struct {
+		proc string
+		line control.Line
+	}
+	entries := []entry{}
+	for _, n := range names {
+		lines, err := c.Logs(ctx, n, 0)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			entries = append(entries, entry{proc: n, line: line})
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int {
+		return a.line.Time.Compare(b.line.Time)
+	})
+
+	// Partial lines are overwritten, only print them once they're done
+	printed := map[string]int{}
+	for _, e := range entries {
+		if e.line.Partial {
+			continue
+		}
+
+		write(e.proc, e.line)
+		printed[e.proc] = e.line.Index + 1
+	}
+
+	if !follow {
+		return nil
+	}
+
+	// Reading stops once ctx is done, ending the request
+	for {
+		e, err := events.Next()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if e.Type != control.EventLog || e.Line.Partial || e.Line.Index < printed[e.Proc] {
+			continue
+		}
+
+		// Events are dropped when this can't keep up, get the lines that were missed
+		if e.Line.Index > printed[e.Proc] {
+			lines, err := c.Logs(ctx, e.Proc, 0)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			for _, line := range lines {
+				if line.Partial || line.Index < printed[e.Proc] || line.Index >= e.Line.Index {
+					continue
+				}
+
+				write(e.Proc, line)
+			}
+		}
+
+		write(e.Proc, *e.Line)
+		printed[e.Proc] = e.Line.Index + 1
+	}
+}
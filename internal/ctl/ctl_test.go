@@ -0,0 +1,175 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spotdemo4/treli/internal/control/controltest"
+	"github.com/spotdemo4/treli/internal/logs"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+func TestControl(t *testing.T) {
+	tests := []struct {
+		name   string
+		action Action
+		names  []string
+		all    bool
+		want   string
+		code   int
+	}{
+		{
+			name:   "start all skips running",
+			action: ActionStart,
+			all:    true,
+			want:   "idle started\n",
+		},
+		{
+			name:   "stop all skips stopped",
+			action: ActionStop,
+			all:    true,
+			want:   "running stopped\n",
+		},
+		{
+			name:   "restart all",
+			action: ActionRestart,
+			all:    true,
+			want:   "running restarted\nidle restarted\n",
+		},
+		{
+			name:   "all ignores names",
+			action: ActionStop,
+			names:  []string{"idle"},
+			all:    true,
+			want:   "running stopped\n",
+		},
+		{
+			name:   "names",
+			action: ActionRestart,
+			names:  []string{"idle", "running"},
+			want:   "idle restarted\nrunning restarted\n",
+		},
+		{
+			name:   "start running",
+			action: ActionStart,
+			names:  []string{"running", "idle"},
+			want:   "Could not start running: " + proc.ErrStarted.Error() + "\nidle started\n",
+			code:   1,
+		},
+		{
+			name:   "stop stopped",
+			action: ActionStop,
+			names:  []string{"idle"},
+			want:   "Could not stop idle: " + proc.ErrNotStarted.Error() + "\n",
+			code:   1,
+		},
+		{
+			name:   "missing",
+			action: ActionStop,
+			names:  []string{"missing"},
+			want:   "Could not stop missing: proc missing not found\n",
+			code:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := proc.NewBus()
+			// The shell can't exec sleep, so stopping has to reach it too
+			run := controltest.Proc(t, bus, "running", proc.KindService, "sleep 60 && echo done")
+			idle := controltest.Proc(t, bus, "idle", proc.KindService, "exec sleep 60")
+			c := controltest.Serve(t, bus, run, idle)
+
+			go run.Start(context.Background(), proc.TriggerManual)
+			controltest.Eventually(t, "running to run", func() bool { return run.State() == proc.StateRunning })
+
+			out := &bytes.Buffer{}
+			if code := Control(context.Background(), c, tt.action, tt.names, tt.all, out); code != tt.code {
+				t.Errorf("Control() = %d, want %d", code, tt.code)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+// slowWriter calls first on the first write, so the reader falls behind
+type slowWriter struct {
+	first func()
+	once  sync.Once
+	mu    sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	w.once.Do(w.first)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.Write(p)
+}
+
+func (w *slowWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.String()
+}
+
+func TestLogsFollowGaps(t *testing.T) {
+	const lines = 50_000
+
+	bus := proc.NewBus()
+	first := controltest.Proc(t, bus, "first", proc.KindService, "echo first")
+	many := controltest.Proc(t, bus, "many", proc.KindService, fmt.Sprintf("seq %d", lines))
+	first.Start(context.Background(), proc.TriggerManual)
+	c := controltest.Serve(t, bus, first, many)
+
+	// Printing the first line stalls long enough that the events of many are coalesced
+	out := &slowWriter{first: func() {
+		many.Start(context.Background(), proc.TriggerManual)
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan int)
+	go func() {
+		done <- Logs(ctx, c, "", logs.All, true, out)
+	}()
+
+	// Every line is printed once, in order
+	want := []string{"first"}
+	for i := range lines {
+		want = append(want, fmt.Sprint(i+1))
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for strings.Count(out.String(), "\n") < len(want) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if code := <-done; code != 0 {
+		t.Errorf("Logs() = %d, want 0", code)
+	}
+
+	got := []string{}
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		fields := strings.Fields(line)
+		got = append(got, fields[len(fields)-1])
+	}
+	if len(got) != len(want) {
+		t.Fatalf("printed %d lines, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("line %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
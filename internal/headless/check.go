@@ -0,0 +1,161 @@
+package headless
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+// result is how a task did in a check
+type result struct {
+	status   string
+	duration time.Duration
+}
+
+const (
+	statusPass    = "pass"
+	statusFail    = "fail"
+	statusSkipped = "skipped"
+)
+
+// Check runs every task once, at most jobs at a time, each one after the tasks it depends on passed.
+// Restart policies are ignored, so a task that fails is reported as failed instead of retried.
+// Output is streamed to out like Run, followed by a summary.
+// Returns the exit code, which is 1 if a task failed or was skipped
+func Check(ctx context.Context, procs []*proc.Proc, bus *proc.Bus, out io.Writer, jobs int) int {
+	p := newPrinter(procs, out)
+	stop := p.stream(bus)
+
+	// Tasks are done once they've run, or were skipped
+	tasks := map[string]*proc.Proc{}
+	done := map[string]chan struct{}{}
+	for _, a := range procs {
+		if a.Kind == proc.KindTask {
+			tasks[a.Name] = a
+			done[a.Name] = make(chan struct{})
+			a.Restart.Policy = proc.RestartNever
+		}
+	}
+
+	var mu sync.Mutex
+	results := map[string]result{}
+	sem := make(chan struct{}, max(jobs, 1))
+
+	var wg sync.WaitGroup
+	for _, a := range procs {
+		if a.Kind != proc.KindTask {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[a.Name])
+
+			res := result{
+				status: statusPass,
+			}
+			defer func() {
+				mu.Lock()
+				results[a.Name] = res
+				mu.Unlock()
+			}()
+
+			// Wait for dependencies, services don't run so they're ignored
+			trigger := proc.TriggerManual
+			for _, dep := range a.DependsOn {
+				if _, ok := tasks[dep]; !ok {
+					continue
+				}
+				<-done[dep]
+				trigger = proc.TriggerDependency
+
+				mu.Lock()
+				passed := results[dep].status == statusPass
+				mu.Unlock()
+
+				if !passed {
+					p.system("%s skipped, %s didn't pass", a.Name, dep)
+					res.status = statusSkipped
+					return
+				}
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				res.status = statusSkipped
+				return
+			}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			err := a.Start(ctx, trigger)
+			res.duration = time.Since(start)
+			if err != nil {
+				res.status = statusFail
+			}
+		}()
+	}
+	wg.Wait()
+	stop()
+
+	code := 0
+	rows := [][]string{}
+	for _, a := range procs {
+		res, ok := results[a.Name]
+		if !ok {
+			continue
+		}
+		if res.status != statusPass {
+			code = 1
+		}
+
+		duration := ""
+		if res.status != statusSkipped {
+			duration = res.duration.Round(time.Millisecond).String()
+		}
+
+		rows = append(rows, []string{a.Name, res.status, duration})
+	}
+
+	fmt.Fprintln(out, p.summary(rows))
+
+	return code
+}
+
+// summary renders the results of a check as a table
+func (p *printer) summary(rows [][]string) string {
+	header := p.r.NewStyle().Bold(true).Padding(0, 1)
+	cell := p.r.NewStyle().Padding(0, 1)
+	statuses := map[string]lipgloss.Style{
+		statusPass:    cell.Foreground(lipgloss.Color("#a6e3a1")),
+		statusFail:    cell.Foreground(lipgloss.Color("#f38ba8")),
+		statusSkipped: cell.Foreground(lipgloss.Color("#f9e2af")),
+	}
+
+	return table.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(p.r.NewStyle().Foreground(lipgloss.Color("#45475a"))).
+		Headers("proc", "result", "duration").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			switch {
+			case row == table.HeaderRow:
+				return header
+			case col == 0:
+				return p.styles[rows[row][0]].Padding(0, 1)
+			case col == 1:
+				return statuses[rows[row][1]]
+			default:
+				return cell
+			}
+		}).
+		String()
+}
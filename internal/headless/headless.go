@@ -0,0 +1,183 @@
+package headless
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+	"github.com/muesli/termenv"
+	"github.com/spotdemo4/treli/internal/proc"
+	"github.com/spotdemo4/treli/internal/util"
+)
+
+// system is the prefix of lines from treli itself
+const system = "system"
+
+// Run starts the autostart procs and streams the output of every proc to out, prefixed with its name.
+// If exit is set and every autostart proc runs once, it returns when they're done, otherwise once ctx is done.
+// Returns the exit code, which is 1 if the last run of a proc that runs once failed
+func Run(ctx context.Context, procs []*proc.Proc, bus *proc.Bus, out io.Writer, exit bool) int {
+	p := newPrinter(procs, out)
+	stop := p.stream(bus)
+
+	// Start apps
+	var wg sync.WaitGroup
+	services := false
+	for _, a := range procs {
+		if !a.AutoStart {
+			continue
+		}
+		if !runsOnce(a) {
+			services = true
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.Start(ctx, proc.TriggerAutostart)
+		}()
+	}
+
+	// Wait for procs that run once if exiting when they're done, otherwise until stopped
+	if services || !exit {
+		<-ctx.Done()
+	}
+	wg.Wait()
+	for _, a := range procs {
+		a.Wait()
+	}
+
+	stop()
+
+	code := 0
+	for _, a := range procs {
+		history := a.History()
+		if runsOnce(a) && len(history) > 0 && history[len(history)-1].Failed() {
+			p.system("%s failed", a.Name)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// runsOnce returns whether p stops by itself, instead of running until it's stopped
+func runsOnce(p *proc.Proc) bool {
+	return p.Kind == proc.KindTask
+}
+
+type printer struct {
+	r      *lipgloss.Renderer
+	out    io.Writer
+	color  bool
+	names  map[uuid.UUID]string
+	styles map[string]lipgloss.Style
+	width  int
+}
+
+func newPrinter(procs []*proc.Proc, out io.Writer) *printer {
+	// Color even when out isn't a terminal, CI logs and docker logs show colors too
+	color := os.Getenv("NO_COLOR") == ""
+	r := lipgloss.NewRenderer(out)
+	if color {
+		r.SetColorProfile(termenv.TrueColor)
+	} else {
+		r.SetColorProfile(termenv.Ascii)
+	}
+
+	p := &printer{
+		r:     r,
+		out:   out,
+		color: color,
+		names: map[uuid.UUID]string{},
+		styles: map[string]lipgloss.Style{
+			system: r.NewStyle().Foreground(lipgloss.Color("#a6adc8")),
+		},
+		width: len(system),
+	}
+
+	for _, a := range procs {
+		p.names[a.ID] = a.Name
+		p.styles[a.Name] = r.NewStyle().Foreground(lipgloss.Color(a.Color))
+		p.width = max(p.width, len(a.Name))
+	}
+
+	return p
+}
+
+// stream prints the events of procs, waiting when out can't keep up.
+// Calling stop prints what's left and stops
+func (p *printer) stream(bus *proc.Bus) (stop func()) {
+	events := bus.Subscribe(proc.Block, 100)
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+
+		for {
+			e, ok := events.Next()
+			if !ok {
+				return
+			}
+
+			p.print(e)
+		}
+	}()
+
+	return func() {
+		events.Close()
+		<-printed
+	}
+}
+
+func (p *printer) print(e proc.Event) {
+	name := p.names[e.ProcID()]
+
+	switch e := e.(type) {
+	case proc.LogAppended:
+		// Partial lines are overwritten, only print them once they're done
+		if e.Line.Partial {
+			return
+		}
+
+		text := e.Line.Text
+		if !p.color {
+			text = util.StripANSI(text)
+		} else if strings.Contains(text, "\x1b") {
+			// Don't let colors carry on to the next line
+			text += "\x1b[0m"
+		}
+
+		p.line(name, text)
+
+	case proc.Triggered:
+		p.system("%s run %d (%s) started by %s", name, e.Run, e.Variant, e.Trigger)
+
+	case proc.StateChanged:
+		// Starting is printed when triggered
+		if e.State != proc.StateStarting {
+			p.system("%s %s", name, e.State)
+		}
+
+	case proc.ConfigReloaded:
+		if e.Err != nil {
+			p.system("config: %s", e.Err)
+		} else {
+			p.system("config changed, restart treli to apply it")
+		}
+	}
+}
+
+func (p *printer) system(msg string, ext ...any) {
+	p.line(system, fmt.Sprintf(msg, ext...))
+}
+
+// line prints text prefixed with name
+func (p *printer) line(name string, text string) {
+	prefix := p.styles[name].Render(fmt.Sprintf("%-*s |", p.width, name))
+	fmt.Fprintf(p.out, "%s %s\n", prefix, text)
+}
@@ -0,0 +1,92 @@
+package headless
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+// buffer is a bytes.Buffer that can be read while it's written to
+type buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *buffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		exit    bool
+		onstart string
+		code    int
+		returns bool
+	}{
+		{name: "exit", exit: true, onstart: "echo built", returns: true},
+		{name: "exit failed", exit: true, onstart: "echo built; exit 1", code: 1, returns: true},
+		{name: "keep running", onstart: "echo built"},
+		{name: "keep running failed", onstart: "echo built; exit 1", code: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", "1")
+
+			bus := proc.NewBus()
+			defer bus.Close()
+			p := proc.New("build", proc.Options{AutoStart: true, Kind: proc.KindTask, OnStart: tt.onstart, Dir: t.TempDir()}, bus)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			out := &buffer{}
+			done := make(chan int)
+			go func() {
+				done <- Run(ctx, []*proc.Proc{p}, bus, out, tt.exit)
+			}()
+
+			// Wait for the task to finish, then see if Run returned by itself
+			deadline := time.Now().Add(5 * time.Second)
+			for len(p.History()) == 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			var code int
+			select {
+			case code = <-done:
+				if !tt.returns {
+					t.Fatal("Run() returned before ctx was done")
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.returns {
+					t.Fatal("Run() is still running after the task finished")
+				}
+				cancel()
+				code = <-done
+			}
+
+			if code != tt.code {
+				t.Errorf("Run() = %d, want %d", code, tt.code)
+			}
+			if !strings.Contains(out.String(), "build  | built\n") {
+				t.Errorf("output = %q, want the task's output", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// poll is how often a followed log is checked for new output
+const poll = 250 * time.Millisecond
+
+// Read writes the log of name in dir to out, rotated files first.
+// With follow, it keeps writing new output, including after the log is rotated, until ctx is done
+func Read(ctx context.Context, dir string, name string, follow bool, out io.Writer) error {
+	path := Path(dir, name)
+
+	older, err := rotations(path)
+	if err != nil {
+		return err
+	}
+	for _, p := range older {
+		if err := copyFile(p, out); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && len(older) == 0 && !follow:
+		return err
+	case errors.Is(err, os.ErrNotExist):
+		f = nil
+	case err != nil:
+		return err
+	}
+	if f != nil {
+		defer func() { f.Close() }()
+		if _, err := io.Copy(out, f); err != nil {
+			return err
+		}
+	}
+	if !follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// The log hasn't been written to yet
+		if f == nil {
+			f, err = os.Open(path)
+			if errors.Is(err, os.ErrNotExist) {
+				f = nil
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := io.Copy(out, f); err != nil {
+			return err
+		}
+
+		// Once rotated, the rest of the old file was read above, continue with the new one
+		current, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		open, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(current, open) {
+			f.Close()
+			f = nil
+		}
+	}
+}
+
+// rotations returns the rotated files of path, oldest first
+func rotations(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	rotated := map[string]int{}
+	for _, m := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(m, path+"."))
+		if err == nil {
+			rotated[m] = n
+		}
+	}
+
+	paths := []string{}
+	for p := range rotated {
+		paths = append(paths, p)
+	}
+	slices.SortFunc(paths, func(a, b string) int {
+		return rotated[b] - rotated[a]
+	})
+
+	return paths, nil
+}
+
+func copyFile(path string, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(out, f)
+
+	return err
+}
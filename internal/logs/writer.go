@@ -0,0 +1,194 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+// All is the name of the log with the output of every proc
+const All = "all"
+
+// Writer writes the output of procs to <dir>/<proc>.log and <dir>/all.log,
+// rotating a file once it's bigger than maxSize bytes and keeping maxFiles rotated files.
+// Lines are written in the order they're read. stdout and stderr are separate pipes,
+// so a line of one can come before a line of the other that was printed earlier
+type Writer struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+	names    map[uuid.UUID]string
+	width    int
+
+	files map[string]*file
+	err   error
+
+	events  *proc.Subscription
+	written chan struct{}
+	mu      *sync.Mutex
+}
+
+type file struct {
+	path string
+	f    *os.File
+	size int64
+}
+
+// NewWriter creates dir if needed and starts writing the output of procs published to bus
+func NewWriter(dir string, maxSize int64, maxFiles int, procs []*proc.Proc, bus *proc.Bus) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("log dir %s: %w", dir, err)
+	}
+
+	w := &Writer{
+		dir:      dir,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		names:    map[uuid.UUID]string{},
+		files:    map[string]*file{},
+		events:   bus.Subscribe(proc.Block, 100),
+		written:  make(chan struct{}),
+		mu:       &sync.Mutex{},
+	}
+	for _, a := range procs {
+		w.names[a.ID] = a.Name
+		w.width = max(w.width, len(a.Name))
+	}
+
+	go w.run()
+
+	return w, nil
+}
+
+// Close writes what's left and closes every file, returning the first error writing
+func (w *Writer) Close() error {
+	w.events.Close()
+	<-w.written
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, f := range w.files {
+		if err := f.f.Close(); err != nil && w.err == nil {
+			w.err = err
+		}
+	}
+	w.files = map[string]*file{}
+
+	return w.err
+}
+
+func (w *Writer) run() {
+	defer close(w.written)
+
+	for {
+		e, ok := w.events.Next()
+		if !ok {
+			return
+		}
+
+		// Partial lines are overwritten, only write them once they're done
+		l, ok := e.(proc.LogAppended)
+		if !ok || l.Line.Partial {
+			continue
+		}
+		name := w.names[l.Proc]
+
+		w.mu.Lock()
+		w.write(name, Format(l.Line))
+		w.write(All, fmt.Sprintf("%-*s %s", w.width, name, Format(l.Line)))
+		w.mu.Unlock()
+	}
+}
+
+// Format formats a line as it's written to a log file
+func Format(line proc.Line) string {
+	return fmt.Sprintf("%s %s %s\n", line.Time.Format(time.RFC3339), line.Stream, line.Text)
+}
+
+// write appends text to the log of name, only the first error is kept
+func (w *Writer) write(name string, text string) {
+	if err := w.append(name, text); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *Writer) append(name string, text string) error {
+	f, ok := w.files[name]
+	if !ok {
+		path := Path(w.dir, name)
+		of, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return err
+		}
+		info, err := of.Stat()
+		if err != nil {
+			of.Close()
+			return err
+		}
+
+		f = &file{path: path, f: of, size: info.Size()}
+		w.files[name] = f
+	}
+
+	if f.size > 0 && f.size+int64(len(text)) > w.maxSize {
+		if err := w.rotate(name, f); err != nil {
+			return err
+		}
+		f = w.files[name]
+	}
+
+	n, err := f.f.WriteString(text)
+	f.size += int64(n)
+
+	return err
+}
+
+// rotate moves <name>.log to <name>.log.1, shifting older files up and removing the oldest
+func (w *Writer) rotate(name string, f *file) error {
+	delete(w.files, name)
+	if err := f.f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Remove(rotated(f.path, w.maxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := w.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(rotated(f.path, i), rotated(f.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if w.maxFiles > 0 {
+		if err := os.Rename(f.path, rotated(f.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+
+	of, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	w.files[name] = &file{path: f.path, f: of}
+
+	return nil
+}
+
+// Path returns where the log of name is written in dir
+func Path(dir string, name string) string {
+	return filepath.Join(dir, name+".log")
+}
+
+// rotated returns the path of the nth most recently rotated file of path
+func rotated(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
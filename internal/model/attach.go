@@ -0,0 +1,194 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+// detach is the key that leaves attach mode, everything else is forwarded
+const detach = "ctrl+]"
+
+// pendingKeys is how many keys can wait to be written while the proc isn't reading them
+const pendingKeys = 256
+
+// Attach forwards keys to the stdin of a proc
+type Attach struct {
+	style      lipgloss.Style
+	badgeStyle lipgloss.Style
+	labelStyle lipgloss.Style
+	inputStyle lipgloss.Style
+	errStyle   lipgloss.Style
+
+	// The proc keys are sent to, nil when not attached
+	Proc *proc.Proc
+
+	// Input typed since the last enter, shown when the proc doesn't echo it
+	line []rune
+
+	// Keys waiting to be written, writing can block so it happens in the background
+	keys chan []byte
+	err  error
+	mu   *sync.Mutex
+}
+
+func NewAttach() *Attach {
+	return &Attach{
+		style: lipgloss.NewStyle().Padding(1, 2).AlignVertical(lipgloss.Bottom).Height(1),
+		badgeStyle: lipgloss.NewStyle().
+			Padding(0, 1).
+			Background(lipgloss.Color("#fab387")).
+			Foreground(lipgloss.Color("#11111b")).
+			Bold(true),
+		labelStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("#a6adc8")),
+		inputStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("#cdd6f4")),
+		errStyle:   lipgloss.NewStyle().Foreground(lipgloss.Color("#f38ba8")),
+		mu:         &sync.Mutex{},
+	}
+}
+
+func (a *Attach) Open(p *proc.Proc) {
+	a.Proc = p
+	a.line = nil
+	a.setErr(nil)
+	if !p.TakesInput() {
+		a.setErr(proc.ErrNoInput)
+	}
+
+	a.keys = make(chan []byte, pendingKeys)
+	go a.write(p, a.keys)
+}
+
+func (a *Attach) Close() {
+	close(a.keys)
+	a.Proc = nil
+	a.line = nil
+	a.setErr(nil)
+}
+
+// write writes keys to p in order, until keys is closed
+func (a *Attach) write(p *proc.Proc, keys chan []byte) {
+	for b := range keys {
+		if _, err := p.Write(b); err != nil {
+			a.setErr(err)
+		}
+	}
+}
+
+// Send queues the key to be written to the proc
+func (a *Attach) Send(msg tea.KeyMsg) {
+	b := keyBytes(msg, a.Proc.UsesPty())
+	if b == nil || !a.Proc.TakesInput() {
+		return
+	}
+
+	a.setErr(nil)
+	select {
+	case a.keys <- b:
+	default:
+		a.setErr(errors.New("input dropped, the proc isn't reading it"))
+		return
+	}
+	if a.Proc.UsesPty() {
+		return
+	}
+
+	// Keep track of the line being typed, as nothing echoes it
+	switch msg.Type {
+	case tea.KeyEnter, tea.KeyCtrlC, tea.KeyCtrlD, tea.KeyCtrlU:
+		a.line = nil
+	case tea.KeyBackspace:
+		if len(a.line) > 0 {
+			a.line = a.line[:len(a.line)-1]
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		a.line = append(a.line, msg.Runes...)
+	}
+}
+
+// Gen renders the indicator shown while attached, empty if not attached
+func (a *Attach) Gen(width int) string {
+	if a.Proc == nil {
+		return ""
+	}
+
+	items := []string{
+		a.badgeStyle.Render("ATTACHED " + a.Proc.Name),
+		a.labelStyle.Render("keys are sent to the proc • " + detach + " detach"),
+	}
+
+	if err := a.getErr(); err != nil {
+		items = append(items, a.errStyle.Render(err.Error()))
+	} else if len(a.line) > 0 {
+		items = append(items, a.inputStyle.Render("> "+string(a.line)))
+	}
+
+	return a.style.Width(width).Render(strings.Join(items, " "))
+}
+
+func (a *Attach) setErr(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.err = err
+}
+
+func (a *Attach) getErr() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.err
+}
+
+// keyBytes returns what a terminal would send for the key, nil if it can't be sent
+func keyBytes(msg tea.KeyMsg, pty bool) []byte {
+	var b []byte
+
+	switch msg.Type {
+	case tea.KeyRunes:
+		b = []byte(string(msg.Runes))
+	case tea.KeySpace:
+		b = []byte(" ")
+	case tea.KeyEnter:
+		// Pseudo-terminals turn carriage returns into newlines themselves
+		b = []byte("\n")
+		if pty {
+			b = []byte("\r")
+		}
+	case tea.KeyUp:
+		b = []byte("\x1b[A")
+	case tea.KeyDown:
+		b = []byte("\x1b[B")
+	case tea.KeyRight:
+		b = []byte("\x1b[C")
+	case tea.KeyLeft:
+		b = []byte("\x1b[D")
+	case tea.KeyHome:
+		b = []byte("\x1b[H")
+	case tea.KeyEnd:
+		b = []byte("\x1b[F")
+	case tea.KeyPgUp:
+		b = []byte("\x1b[5~")
+	case tea.KeyPgDown:
+		b = []byte("\x1b[6~")
+	case tea.KeyDelete:
+		b = []byte("\x1b[3~")
+	case tea.KeyShiftTab:
+		b = []byte("\x1b[Z")
+	default:
+		// Control characters are their own key type
+		if msg.Type >= 0 && msg.Type <= 0x7f {
+			b = []byte{byte(msg.Type)}
+		}
+	}
+
+	if b != nil && msg.Alt {
+		b = append([]byte("\x1b"), b...)
+	}
+
+	return b
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spotdemo4/treli/internal/proc"
+	"github.com/spotdemo4/treli/internal/settings"
+)
+
+const (
+	benchProcs = 10
+	benchLines = 10_000
+)
+
+// logged returns procs that each ran once, printing lines lines of output
+func logged(tb testing.TB, procs int, lines int) []*proc.Proc {
+	tb.Helper()
+
+	bus := proc.NewBus()
+	ps := []*proc.Proc{}
+	for i := range procs {
+		p := proc.New(fmt.Sprintf("proc%d", i), proc.Options{
+			Color:   "#89b4fa",
+			Kind:    proc.KindTask,
+			OnStart: fmt.Sprintf("seq -f 'line %%g of the output of a proc, long enough to wrap on narrow terminals' %d", lines),
+			Dir:     tb.TempDir(),
+		}, bus)
+		if err := p.Start(context.Background(), proc.TriggerManual); err != nil {
+			tb.Fatal(err)
+		}
+
+		ps = append(ps, p)
+	}
+
+	return ps
+}
+
+// feedRows returns the rows of every line of procs, merged like the runner does
+func feedRows(procs []*proc.Proc) []*Row {
+	f := NewFeed(procs)
+	f.Pull()
+
+	rows := []*Row{}
+	for _, item := range f.items {
+		rows = append(rows, item.row)
+	}
+
+	return rows
+}
+
+func BenchmarkFeedPull(b *testing.B) {
+	procs := logged(b, benchProcs, benchLines)
+
+	for b.Loop() {
+		f := NewFeed(procs)
+		if f.Pull() == -1 {
+			b.Fatal("no lines")
+		}
+	}
+}
+
+// BenchmarkTerminalRender renders the visible window of a terminal that's already rendered once
+func BenchmarkTerminalRender(b *testing.B) {
+	rows := feedRows(logged(b, benchProcs, benchLines))
+
+	t := NewTerminal(len("proc0") + 1)
+	t.SetRows(rows)
+	t.Gen(200, 50, 0, 0)
+
+	for b.Loop() {
+		t.Gen(200, 50, 0, 0)
+	}
+}
+
+// BenchmarkTerminalResize renders after every width change, so the visible rows wrap again each time
+func BenchmarkTerminalResize(b *testing.B) {
+	rows := feedRows(logged(b, benchProcs, benchLines))
+
+	t := NewTerminal(len("proc0") + 1)
+	t.SetRows(rows)
+
+	width := 0
+	for b.Loop() {
+		width = (width + 1) % 100
+		t.Gen(80+width, 50, 0, 0)
+	}
+}
+
+// BenchmarkTerminalScroll renders while scrolling up through every row
+func BenchmarkTerminalScroll(b *testing.B) {
+	rows := feedRows(logged(b, benchProcs, benchLines))
+
+	t := NewTerminal(len("proc0") + 1)
+	t.SetRows(rows)
+	t.Gen(200, 50, 0, 0)
+
+	for b.Loop() {
+		t.ScrollUp(10)
+		t.Gen(200, 50, 0, 0)
+	}
+}
+
+// BenchmarkTerminalAppend renders after new lines arrive at the bottom
+func BenchmarkTerminalAppend(b *testing.B) {
+	rows := feedRows(logged(b, benchProcs, benchLines))
+
+	t := NewTerminal(len("proc0") + 1)
+	t.SetRows(rows)
+	t.Gen(200, 50, 0, 0)
+
+	for b.Loop() {
+		t.Append(&Row{
+			Time:   time.Now(),
+			Prefix: "proc0",
+			Text:   "a new line",
+			Plain:  "a new line",
+			Color:  "#89b4fa",
+		})
+		t.Gen(200, 50, 0, 0)
+	}
+}
+
+// BenchmarkRunnerView pulls every line into a runner and renders the whole UI
+func BenchmarkRunnerView(b *testing.B) {
+	procs := logged(b, benchProcs, benchLines)
+	bus := proc.NewBus()
+	defer bus.Close()
+
+	for b.Loop() {
+		var m tea.Model = NewRunner(context.Background(), procs, bus, settings.Layout{}, "")
+		m, _ = m.Update(tea.WindowSizeMsg{Width: 200, Height: 50})
+		m, _ = m.Update(proc.StateChanged{Proc: procs[0].ID, State: proc.StateSuccess})
+		m.View()
+	}
+}
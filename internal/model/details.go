@@ -0,0 +1,97 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+// detailsRows is the max number of runs shown in the details pane
+const detailsRows = 8
+
+type Details struct {
+	style   lipgloss.Style
+	header  lipgloss.Style
+	row     lipgloss.Style
+	warning lipgloss.Style
+	show    bool
+}
+
+func NewDetails() *Details {
+	s := lipgloss.NewStyle().
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("#45475a")).
+		BorderTop(true).
+		Margin(1, 0, 0).
+		Padding(0, 1)
+
+	return &Details{
+		style:   s,
+		header:  lipgloss.NewStyle().Foreground(lipgloss.Color("#a6adc8")).Bold(true),
+		row:     lipgloss.NewStyle().Foreground(lipgloss.Color("#cdd6f4")),
+		warning: lipgloss.NewStyle().Foreground(lipgloss.Color("#f9e2af")),
+		show:    false,
+	}
+}
+
+func (d *Details) Gen(p *proc.Proc, width int) string {
+	if !d.show {
+		return ""
+	}
+
+	history := p.History()
+	slices.Reverse(history)
+	if len(history) > detailsRows {
+		history = history[:detailsRows]
+	}
+
+	rows := []string{}
+
+	// Problems found with the config, like a program that isn't installed
+	if warnings := p.Warnings(); len(warnings) > 0 {
+		rows = append(rows, d.header.Render(fmt.Sprintf("%s warnings", p.Name)))
+		for _, w := range warnings {
+			rows = append(rows, d.warning.Render(w))
+		}
+		rows = append(rows, "")
+	}
+
+	rows = append(rows,
+		d.header.Render(fmt.Sprintf("%s runs", p.Name)),
+		d.header.Render(fmt.Sprintf("%-5s %-15s %-9s %-9s %-10s %s", "#", "trigger", "command", "started", "duration", "result")),
+	)
+	if len(history) == 0 {
+		rows = append(rows, d.row.Render("no runs yet"))
+	}
+
+	for _, r := range history {
+		result := checkmark
+		if r.Failed() {
+			result = xmark
+		}
+
+		exit := fmt.Sprintf("exit %d", r.ExitCode)
+		if r.Signal != "" {
+			exit = r.Signal
+		}
+
+		rows = append(rows, d.row.Render(fmt.Sprintf(
+			"%-5d %-15s %-9s %-9s %-10s ",
+			r.ID,
+			r.Trigger,
+			r.Variant,
+			r.Start.Format(time.Kitchen),
+			r.Duration.Round(time.Millisecond),
+		))+result+" "+d.row.Render(exit))
+	}
+
+	return d.style.Width(width).Render(strings.Join(rows, "\n"))
+}
+
+func (d *Details) Toggle() {
+	d.show = !d.show
+}
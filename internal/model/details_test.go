@@ -0,0 +1,23 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/spotdemo4/treli/internal/proc"
+)
+
+func TestDetailsWarnings(t *testing.T) {
+	p := proc.New("app", proc.Options{OnStart: "treli-missing-program", Dir: t.TempDir()}, nil)
+
+	d := NewDetails()
+	d.Toggle()
+	out := ansi.Strip(d.Gen(p, testWidth))
+
+	for _, want := range []string{"app warnings", "onstart: exec: \"treli-missing-program\"", "app runs"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("details = %q, want %q in it", out, want)
+		}
+	}
+}
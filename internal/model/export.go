@@ -0,0 +1,53 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// exportText returns rows as plain text, one line per row prefixed like the terminal
+func exportText(rows []*Row) string {
+	width := 0
+	for _, row := range rows {
+		width = max(width, ansi.StringWidth(row.Prefix))
+	}
+
+	var b strings.Builder
+	for _, row := range rows {
+		fmt.Fprintf(&b, "%s %-*s | %s\n", row.Time.Format(time.TimeOnly), width, row.Prefix, row.Plain)
+	}
+
+	return b.String()
+}
+
+// exportFile writes text to a new file in dir named after what was exported, returning its path
+func exportFile(dir string, name string, text string) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("treli-%s-%s.log", name, time.Now().Format("20060102-150405")))
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// copyText copies text to the clipboard with OSC52, which the terminal handles so it works over ssh too
+func copyText(text string) error {
+	seq := osc52.New(text)
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case strings.HasPrefix(os.Getenv("TERM"), "screen"):
+		seq = seq.Screen()
+	}
+
+	// Stderr is the same terminal, without racing the renderer on stdout
+	_, err := seq.WriteTo(os.Stderr)
+
+	return err
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/spotdemo4/treli/internal/proc"
+	"github.com/spotdemo4/treli/internal/util"
+)
+
+type feedItem struct {
+	proc *proc.Proc
+	run  int
+	row  *Row
+}
+
+// Feed merges the logs of every proc, ordered by capture time
+type Feed struct {
+	procs   []*proc.Proc
+	cursors []int
+	items   []feedItem
+
+	// The last row of each proc, if it's a partial line that can still change
+	partial []*Row
+}
+
+func NewFeed(procs []*proc.Proc) *Feed {
+	return &Feed{
+		procs:   procs,
+		cursors: make([]int, len(procs)),
+		items:   []feedItem{},
+		partial: make([]*Row, len(procs)),
+	}
+}
+
+// Pull merges lines logged since the last pull into the feed.
+// Returns the index of the first item that changed, or -1 if there were no new lines
+func (f *Feed) Pull() int {
+	added := []feedItem{}
+	for i, p := range f.procs {
+		// Fetch the partial line again, to pick up changes
+		from := f.cursors[i]
+		if f.partial[i] != nil {
+			from--
+		}
+
+		lines := p.LogsSince(from)
+		f.cursors[i] = from + len(lines)
+		if len(lines) == 0 {
+			continue
+		}
+
+		if row := f.partial[i]; row != nil {
+			row.update(lines[0].Text)
+			f.partial[i] = nil
+			if lines[0].Partial {
+				f.partial[i] = row
+			}
+
+			lines = lines[1:]
+		}
+
+		for _, line := range lines {
+			added = append(added, feedItem{
+				proc: p,
+				run:  line.Run,
+				row: &Row{
+					Time:   line.Time,
+					Prefix: p.Name,
+					Text:   line.Text,
+					Plain:  util.StripANSI(line.Text),
+					Color:  p.Color,
+				},
+			})
+
+			if line.Partial {
+				f.partial[i] = added[len(added)-1].row
+			}
+		}
+	}
+	if len(added) == 0 {
+		return -1
+	}
+
+	slices.SortStableFunc(added, compareItems)
+
+	// New lines usually come after everything already in the feed
+	at := sort.Search(len(f.items), func(i int) bool {
+		return f.items[i].row.Time.After(added[0].row.Time)
+	})
+	if at == len(f.items) {
+		f.items = append(f.items, added...)
+		return at
+	}
+
+	// Otherwise merge them into the tail
+	tail := slices.Clone(f.items[at:])
+	f.items = f.items[:at]
+	for len(tail) > 0 && len(added) > 0 {
+		if compareItems(added[0], tail[0]) < 0 {
+			f.items = append(f.items, added[0])
+			added = added[1:]
+		} else {
+			f.items = append(f.items, tail[0])
+			tail = tail[1:]
+		}
+	}
+	f.items = append(f.items, tail...)
+	f.items = append(f.items, added...)
+
+	return at
+}
+
+func compareItems(a, b feedItem) int {
+	return a.row.Time.Compare(b.row.Time)
+}
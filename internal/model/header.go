@@ -25,9 +25,39 @@ func (h *Header) Gen(width int, items ...string) string {
 	return s.Render(pp)
 }
 
-func (h *Header) GenItem(text string) string {
-	return lipgloss.NewStyle().
+// ItemAt returns the index of the item at x and y in what Gen renders, -1 if there isn't one there
+func (h *Header) ItemAt(width int, x int, y int, items ...string) int {
+	if y < h.style.GetMarginTop() || y >= lipgloss.Height(h.Gen(width, items...)) {
+		return -1
+	}
+
+	// Items are centered
+	left := (width - lipgloss.Width(lipgloss.JoinHorizontal(lipgloss.Center, items...))) / 2
+	for i, item := range items {
+		w := lipgloss.Width(item)
+		if x >= left && x < left+w {
+			return i
+		}
+
+		left += w
+	}
+
+	return -1
+}
+
+func (h *Header) GenItem(text string, selected bool) string {
+	s := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#cdd6f4")).
 		Margin(0, 1).
-		Render(text)
+		BorderBottom(true).
+		BorderStyle(lipgloss.HiddenBorder())
+
+	// Underline the selected item, a border keeps the styles of the text intact
+	if selected {
+		s = s.
+			BorderStyle(lipgloss.ThickBorder()).
+			BorderForeground(lipgloss.Color("#89b4fa"))
+	}
+
+	return s.Render(text)
 }
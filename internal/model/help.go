@@ -13,10 +13,35 @@ type keyMap struct {
 	Down    key.Binding
 	Left    key.Binding
 	Right   key.Binding
+	Select  key.Binding
+	View    key.Binding
+	Hide    key.Binding
+	Search  key.Binding
+	Next    key.Binding
+	Prev    key.Binding
+	Filter  key.Binding
 	Start   key.Binding
 	Restart key.Binding
-	Help    key.Binding
-	Quit    key.Binding
+	Details key.Binding
+	Attach  key.Binding
+
+	Export    key.Binding
+	ExportRun key.Binding
+	Copy      key.Binding
+	CopyRun   key.Binding
+
+	RestartAll    key.Binding
+	RestartFailed key.Binding
+
+	Split      key.Binding
+	Orient     key.Binding
+	Zoom       key.Binding
+	Focus      key.Binding
+	AddPane    key.Binding
+	RemovePane key.Binding
+
+	Help key.Binding
+	Quit key.Binding
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view. It's part
@@ -29,15 +54,25 @@ func (k keyMap) ShortHelp() []key.Binding {
 // key.Map interface.
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Up, k.Down, k.Left, k.Right},      // first column
-		{k.Start, k.Restart, k.Help, k.Quit}, // second column
+		{k.Up, k.Down, k.Left, k.Right},                               // first column
+		{k.Select, k.View, k.Hide, k.Details},                         // second column
+		{k.Search, k.Next, k.Prev, k.Filter},                          // third column
+		{k.Start, k.Restart, k.RestartAll, k.RestartFailed},           // fourth column
+		{k.Split, k.Orient, k.Zoom, k.Focus, k.AddPane, k.RemovePane}, // fifth column
+		{k.Export, k.ExportRun, k.Copy, k.CopyRun},                    // sixth column
+		{k.Attach, k.Help, k.Quit},                                    // seventh column
 	}
 }
 
 type Help struct {
-	keys  keyMap
-	style lipgloss.Style
-	help  help.Model
+	keys      keyMap
+	style     lipgloss.Style
+	msgStyle  lipgloss.Style
+	infoStyle lipgloss.Style
+	help      help.Model
+
+	msg  string
+	info bool
 }
 
 func NewHelp() *Help {
@@ -52,11 +87,39 @@ func NewHelp() *Help {
 		),
 		Left: key.NewBinding(
 			key.WithKeys("left", "h"),
-			key.WithHelp("←/h", "move left"),
+			key.WithHelp("←/h", "select previous"),
 		),
 		Right: key.NewBinding(
 			key.WithKeys("right", "l"),
-			key.WithHelp("→/l", "move right"),
+			key.WithHelp("→/l", "select next"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("1", "2", "3", "4", "5", "6", "7", "8", "9"),
+			key.WithHelp("1-9", "select proc"),
+		),
+		View: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "toggle selected only"),
+		),
+		Hide: key.NewBinding(
+			key.WithKeys("x"),
+			key.WithHelp("x", "toggle hide proc"),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "search"),
+		),
+		Next: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "next match"),
+		),
+		Prev: key.NewBinding(
+			key.WithKeys("N"),
+			key.WithHelp("N", "previous match"),
+		),
+		Filter: key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "toggle only matches"),
 		),
 		Start: key.NewBinding(
 			key.WithKeys("s"),
@@ -66,6 +129,62 @@ func NewHelp() *Help {
 			key.WithKeys("r"),
 			key.WithHelp("r", "restart"),
 		),
+		RestartAll: key.NewBinding(
+			key.WithKeys("R"),
+			key.WithHelp("R", "restart all"),
+		),
+		RestartFailed: key.NewBinding(
+			key.WithKeys("F"),
+			key.WithHelp("F", "restart failed"),
+		),
+		Details: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "toggle run history"),
+		),
+		Attach: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "attach input"),
+		),
+		Export: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "export view to file"),
+		),
+		ExportRun: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("E", "export last run to file"),
+		),
+		Copy: key.NewBinding(
+			key.WithKeys("y"),
+			key.WithHelp("y", "copy view"),
+		),
+		CopyRun: key.NewBinding(
+			key.WithKeys("Y"),
+			key.WithHelp("Y", "copy last run"),
+		),
+		Split: key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", "toggle split panes"),
+		),
+		Orient: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "toggle side by side"),
+		),
+		Zoom: key.NewBinding(
+			key.WithKeys("z"),
+			key.WithHelp("z", "zoom pane"),
+		),
+		Focus: key.NewBinding(
+			key.WithKeys("tab", "shift+tab"),
+			key.WithHelp("tab", "focus next pane"),
+		),
+		AddPane: key.NewBinding(
+			key.WithKeys("+"),
+			key.WithHelp("+", "add pane"),
+		),
+		RemovePane: key.NewBinding(
+			key.WithKeys("-"),
+			key.WithHelp("-", "remove pane"),
+		),
 		Help: key.NewBinding(
 			key.WithKeys("?"),
 			key.WithHelp("?", "toggle help"),
@@ -77,18 +196,40 @@ func NewHelp() *Help {
 	}
 
 	return &Help{
-		keys:  keys,
-		style: lipgloss.NewStyle().Padding(1, 2).AlignVertical(lipgloss.Bottom).Height(1),
-		help:  help.New(),
+		keys:      keys,
+		style:     lipgloss.NewStyle().Padding(1, 2).AlignVertical(lipgloss.Bottom).Height(1),
+		msgStyle:  lipgloss.NewStyle().Foreground(lipgloss.Color("#f38ba8")),
+		infoStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("#a6e3a1")),
+		help:      help.New(),
 	}
 }
 
 func (h *Help) Gen(width int) string {
 	h.help.Width = width
 	render := h.help.View(h.keys)
+	if h.msg != "" {
+		style := h.msgStyle
+		if h.info {
+			style = h.infoStyle
+		}
+		render = style.Render(h.msg) + " " + render
+	}
+
 	return h.style.Render(render)
 }
 
+// SetMessage shows a message before the keys, like when something went wrong
+func (h *Help) SetMessage(msg string) {
+	h.msg = msg
+	h.info = false
+}
+
+// SetInfo shows a message before the keys, like when something was done
+func (h *Help) SetInfo(msg string) {
+	h.msg = msg
+	h.info = true
+}
+
 func (h *Help) Toggle() {
 	h.help.ShowAll = !h.help.ShowAll
 }
@@ -8,6 +8,14 @@ var prefix = lipgloss.NewStyle().
 	Background(lipgloss.Color("#89dceb")).
 	Foreground(lipgloss.Color("#11111b"))
 
+var hidden = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#6c7086")).
+	Strikethrough(true)
+
+var transition = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#6c7086")).
+	Italic(true)
+
 var checkmark = lipgloss.NewStyle().
 	Foreground(lipgloss.Color("#a6e3a1")).
 	Bold(true).
@@ -22,3 +30,13 @@ var pause = lipgloss.NewStyle().
 	Foreground(lipgloss.Color("#f9e2af")).
 	Bold(true).
 	Render("⏸")
+
+var queued = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#89b4fa")).
+	Bold(true).
+	Render("◷")
+
+var crashloop = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("#f38ba8")).
+	Bold(true).
+	Render("↺")
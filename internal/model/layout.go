@@ -0,0 +1,292 @@
+package model
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spotdemo4/treli/internal/proc"
+	"github.com/spotdemo4/treli/internal/settings"
+)
+
+// Pane shows the output of one proc, with its own scroll position
+type Pane struct {
+	proc     *proc.Proc
+	terminal *Terminal
+}
+
+// box is the space taken by a pane, including its title
+type box struct {
+	width  int
+	height int
+}
+
+// Layout tiles procs in panes, side by side when vertical, stacked otherwise
+type Layout struct {
+	titleStyle   lipgloss.Style
+	focusStyle   lipgloss.Style
+	borderStyle  lipgloss.Style
+	maxPrefixLen int
+
+	Panes    []*Pane
+	Split    bool
+	Vertical bool
+	Focus    int
+	Zoom     bool
+}
+
+func NewLayout(procs []*proc.Proc, maxPrefixLen int, saved settings.Layout) *Layout {
+	l := &Layout{
+		titleStyle: lipgloss.NewStyle().
+			Padding(0, 1).
+			Background(lipgloss.Color("#313244")).
+			Foreground(lipgloss.Color("#a6adc8")),
+		focusStyle: lipgloss.NewStyle().
+			Padding(0, 1).
+			Background(lipgloss.Color("#45475a")).
+			Foreground(lipgloss.Color("#cdd6f4")).
+			Bold(true),
+		borderStyle: lipgloss.NewStyle().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderForeground(lipgloss.Color("#45475a")).
+			BorderRight(true),
+		maxPrefixLen: maxPrefixLen,
+
+		Split:    saved.Split == "horizontal" || saved.Split == "vertical",
+		Vertical: saved.Split != "horizontal",
+	}
+
+	for _, name := range saved.Panes {
+		i := slices.IndexFunc(procs, func(p *proc.Proc) bool {
+			return p.Name == name
+		})
+		if i != -1 {
+			l.add(procs[i])
+		}
+	}
+
+	// Splitting needs something to split
+	if len(l.Panes) < 2 {
+		l.Split = false
+	}
+
+	return l
+}
+
+// Toggle splits the view into panes, starting with the selected proc and the ones after it
+// if there weren't panes already. Returns false if there aren't enough procs
+func (l *Layout) Toggle(procs []*proc.Proc, selected int) bool {
+	if l.Split {
+		l.Split = false
+		l.Zoom = false
+		return true
+	}
+
+	if len(procs) < 2 {
+		return false
+	}
+
+	if len(l.Panes) < 2 {
+		l.Panes = nil
+		for i := 0; i < 2; i++ {
+			l.add(procs[(selected+i)%len(procs)])
+		}
+	}
+
+	l.Split = true
+	l.Focus = max(slices.IndexFunc(l.Panes, func(p *Pane) bool {
+		return p.proc == procs[selected]
+	}), 0)
+
+	return true
+}
+
+// Add adds a pane for the first proc that isn't shown yet, returns it or nil if there's no room
+func (l *Layout) Add(procs []*proc.Proc) *Pane {
+	if len(l.Panes) >= settings.MaxPanes {
+		return nil
+	}
+
+	for _, p := range procs {
+		if l.pane(p) == nil {
+			pane := l.add(p)
+			l.Focus = len(l.Panes) - 1
+			return pane
+		}
+	}
+
+	return nil
+}
+
+// Remove removes the focused pane, there's always at least two
+func (l *Layout) Remove() bool {
+	if len(l.Panes) <= 2 {
+		return false
+	}
+
+	l.Panes = slices.Delete(l.Panes, l.Focus, l.Focus+1)
+	l.Focus = min(l.Focus, len(l.Panes)-1)
+
+	return true
+}
+
+// Show shows p in the focused pane, swapping panes if it's already shown
+func (l *Layout) Show(p *proc.Proc) {
+	focused := l.Focused()
+	if focused.proc == p {
+		return
+	}
+
+	if other := l.pane(p); other != nil {
+		i := slices.Index(l.Panes, other)
+		l.Panes[i], l.Panes[l.Focus] = l.Panes[l.Focus], l.Panes[i]
+		return
+	}
+
+	l.Panes[l.Focus] = l.newPane(p)
+}
+
+// Cycle moves the focus to the next pane, or the previous one when backwards
+func (l *Layout) Cycle(backwards bool) {
+	if backwards {
+		l.Focus = (l.Focus - 1 + len(l.Panes)) % len(l.Panes)
+	} else {
+		l.Focus = (l.Focus + 1) % len(l.Panes)
+	}
+}
+
+func (l *Layout) Focused() *Pane {
+	return l.Panes[l.Focus]
+}
+
+// Settings returns the layout to save
+func (l *Layout) Settings() settings.Layout {
+	saved := settings.Layout{
+		Split: "none",
+	}
+	if l.Split && l.Vertical {
+		saved.Split = "vertical"
+	} else if l.Split {
+		saved.Split = "horizontal"
+	}
+
+	for _, pane := range l.Panes {
+		saved.Panes = append(saved.Panes, pane.proc.Name)
+	}
+
+	return saved
+}
+
+// Sizes returns the space for the text of each pane's proc, when there's width by height for all of them
+func (l *Layout) Sizes(width int, height int) map[*proc.Proc]box {
+	sizes := map[*proc.Proc]box{}
+	for i, b := range l.boxes(width, height) {
+		pane := l.Panes[i]
+		sizes[pane.proc] = box{
+			width:  pane.terminal.cols(b.width),
+			height: b.height - 1,
+		}
+	}
+
+	return sizes
+}
+
+// boxes divides width and height between the panes, a zoomed pane takes all of it
+func (l *Layout) boxes(width int, height int) []box {
+	boxes := make([]box, len(l.Panes))
+	if l.Zoom {
+		boxes[l.Focus] = box{width, height}
+		return boxes
+	}
+
+	n := len(l.Panes)
+	for i := range boxes {
+		if l.Vertical {
+			// Every pane but the last has a border on its right
+			w := (width - (n - 1)) / n
+			if i < (width-(n-1))%n {
+				w++
+			}
+			boxes[i] = box{w, height}
+		} else {
+			h := height / n
+			if i < height%n {
+				h++
+			}
+			boxes[i] = box{width, h}
+		}
+	}
+
+	return boxes
+}
+
+// Gen renders the panes, head renders the title of a proc
+func (l *Layout) Gen(width int, height int, head func(p *proc.Proc) string) string {
+	panes := []string{}
+	for i, b := range l.boxes(width, height) {
+		if b.width <= 0 || b.height <= 0 {
+			continue
+		}
+
+		pane := l.Panes[i]
+		focused := i == l.Focus
+
+		// Title bar
+		style := l.titleStyle
+		if focused {
+			style = l.focusStyle
+		}
+
+		title := []string{head(pane.proc)}
+		if focused && l.Zoom {
+			title = append(title, "[zoom]")
+		}
+
+		s := lipgloss.JoinVertical(lipgloss.Left,
+			style.Width(b.width).MaxHeight(1).Render(strings.Join(title, " ")),
+			pane.terminal.render(b.width, b.height-1),
+		)
+
+		if l.Vertical && !l.Zoom && i < len(l.Panes)-1 {
+			s = l.borderStyle.Height(b.height).Render(s)
+		}
+
+		panes = append(panes, s)
+	}
+
+	if l.Vertical {
+		return lipgloss.JoinHorizontal(lipgloss.Top, panes...)
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left, panes...)
+}
+
+func (l *Layout) add(p *proc.Proc) *Pane {
+	pane := l.newPane(p)
+	l.Panes = append(l.Panes, pane)
+
+	return pane
+}
+
+func (l *Layout) newPane(p *proc.Proc) *Pane {
+	t := NewTerminal(l.maxPrefixLen)
+
+	// The title bar and the other panes frame it instead
+	t.style = lipgloss.NewStyle()
+
+	return &Pane{
+		proc:     p,
+		terminal: t,
+	}
+}
+
+// pane returns the pane showing p, nil if it isn't shown
+func (l *Layout) pane(p *proc.Proc) *Pane {
+	for _, pane := range l.Panes {
+		if pane.proc == p {
+			return pane
+		}
+	}
+
+	return nil
+}
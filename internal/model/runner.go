@@ -2,15 +2,20 @@ package model
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/spotdemo4/treli/internal/proc"
+	"github.com/spotdemo4/treli/internal/settings"
 	"github.com/spotdemo4/treli/internal/util"
 )
 
@@ -21,15 +26,28 @@ type Runner struct {
 
 	header   *Header
 	terminal *Terminal
+	details  *Details
+	search   *Search
+	attach   *Attach
+	layout   *Layout
 	help     *Help
 	spinner  spinner.Model
 
 	procs    []*proc.Proc
-	onchange chan int
+	feed     *Feed
+	events   *proc.Subscription
 	selected int
+	single   bool
+	hidden   map[*proc.Proc]bool
+
+	// Whether procs are being stopped to quit
+	quitting *bool
+
+	// Where the view is exported to
+	dir string
 }
 
-func NewRunner(ctx context.Context, procs []*proc.Proc, onchange chan int) *Runner {
+func NewRunner(ctx context.Context, procs []*proc.Proc, bus *proc.Bus, layout settings.Layout, dir string) *Runner {
 	mpl := 0
 
 	for _, app := range procs {
@@ -38,6 +56,17 @@ func NewRunner(ctx context.Context, procs []*proc.Proc, onchange chan int) *Runn
 		}
 	}
 
+	l := NewLayout(procs, mpl+1, layout)
+
+	// Everything is read again from the procs on every event, so one of each is enough
+	events := bus.Subscribe(proc.Coalesce, 1)
+
+	// Start on the proc of the focused pane
+	selected := 0
+	if l.Split {
+		selected = slices.Index(procs, l.Focused().proc)
+	}
+
 	return &Runner{
 		ctx:    ctx,
 		width:  nil,
@@ -45,20 +74,27 @@ func NewRunner(ctx context.Context, procs []*proc.Proc, onchange chan int) *Runn
 
 		header:   NewHeader(),
 		terminal: NewTerminal(mpl + 1),
+		details:  NewDetails(),
+		search:   NewSearch(),
+		attach:   NewAttach(),
+		layout:   l,
 		help:     NewHelp(),
 		spinner:  spinner.New(spinner.WithSpinner(spinner.MiniDot), spinner.WithStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#a6adc8")))),
 
 		procs:    procs,
-		onchange: onchange,
+		feed:     NewFeed(procs),
+		events:   events,
+		selected: selected,
+		hidden:   map[*proc.Proc]bool{},
+		quitting: new(bool),
+		dir:      dir,
 	}
 }
 
 func (m Runner) Init() tea.Cmd {
 	return tea.Batch(
 		m.spinner.Tick,
-		func() tea.Msg {
-			return <-m.onchange
-		},
+		m.next,
 	)
 }
 
@@ -68,11 +104,29 @@ func (m Runner) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 
-	case int:
-		return m, func() tea.Msg {
-			return <-m.onchange
+	case proc.ConfigReloaded:
+		if msg.Err != nil {
+			// Parse errors go on to show where in the file, only the first line fits
+			first, _, _ := strings.Cut(msg.Err.Error(), "\n")
+			m.help.SetMessage("config: " + first)
+		} else {
+			m.help.SetInfo("config changed, restart treli to apply it")
 		}
 
+		return m, m.next
+
+	case proc.Event:
+		n := len(m.feed.items)
+		switch m.feed.Pull() {
+		case -1:
+		case n:
+			m.refresh(n)
+		default:
+			m.refresh(0)
+		}
+
+		return m, m.next
+
 	case spinner.TickMsg:
 		m.spinner, cmd = m.spinner.Update(msg)
 		return m, cmd
@@ -80,101 +134,521 @@ func (m Runner) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = util.IntPointer(msg.Width)
 		m.height = util.IntPointer(msg.Height)
+		m.resize()
 
 	case tea.KeyMsg:
+		// Messages last until the next key
+		if !*m.quitting {
+			m.help.SetMessage("")
+		}
+
+		if m.search.Prompting {
+			return m, m.updateSearch(msg)
+		}
+		if m.attach.Proc != nil {
+			m.updateAttach(msg)
+			return m, nil
+		}
+
 		switch {
+		case key.Matches(msg, m.help.keys.Attach):
+			m.attach.Open(m.procs[m.selected])
+			m.terminal.SetAttached(true)
+
+		case key.Matches(msg, m.help.keys.Search):
+			return m, m.search.Open()
+
+		case key.Matches(msg, m.help.keys.Next):
+			m.find(false)
+
+		case key.Matches(msg, m.help.keys.Prev):
+			m.find(true)
+
+		case key.Matches(msg, m.help.keys.Filter):
+			if m.search.Regexp() != nil {
+				m.search.ToggleFilter()
+				m.refresh(0)
+			}
+
+		case msg.String() == "esc" && m.search.Regexp() != nil:
+			m.search.Clear()
+			m.setHighlight(nil)
+			m.refresh(0)
+
 		case key.Matches(msg, m.help.keys.Help):
 			m.help.Toggle()
 
-		case key.Matches(msg, m.help.keys.Quit):
-			return m, func() tea.Msg {
-				for _, p := range m.procs {
-					(*p).Stop()
-				}
+		case key.Matches(msg, m.help.keys.Left):
+			m.selected = (m.selected - 1 + len(m.procs)) % len(m.procs)
+			m.show()
+
+		case key.Matches(msg, m.help.keys.Right):
+			m.selected = (m.selected + 1) % len(m.procs)
+			m.show()
+
+		case key.Matches(msg, m.help.keys.Select):
+			i := int(msg.Runes[0] - '1')
+			if i < len(m.procs) {
+				m.selected = i
+				m.show()
+			}
+
+		case key.Matches(msg, m.help.keys.View):
+			m.single = !m.single
+			m.refresh(0)
+
+		case key.Matches(msg, m.help.keys.Hide):
+			p := m.procs[m.selected]
+			m.hidden[p] = !m.hidden[p]
+			m.refresh(0)
+
+		case key.Matches(msg, m.help.keys.Details):
+			m.details.Toggle()
+
+		case key.Matches(msg, m.help.keys.Export), key.Matches(msg, m.help.keys.ExportRun):
+			m.export(key.Matches(msg, m.help.keys.ExportRun), false)
+
+		case key.Matches(msg, m.help.keys.Copy), key.Matches(msg, m.help.keys.CopyRun):
+			m.export(key.Matches(msg, m.help.keys.CopyRun), true)
 
-				return tea.QuitMsg{}
+		case key.Matches(msg, m.help.keys.Split):
+			if m.layout.Toggle(m.procs, m.selected) {
+				m.setHighlight(m.search.Regexp())
+				m.refresh(0)
+				m.resize()
 			}
 
+		case key.Matches(msg, m.help.keys.Orient) && m.layout.Split:
+			m.layout.Vertical = !m.layout.Vertical
+			m.resize()
+
+		case key.Matches(msg, m.help.keys.Zoom) && m.layout.Split:
+			m.layout.Zoom = !m.layout.Zoom
+			m.resize()
+
+		case key.Matches(msg, m.help.keys.Focus) && m.layout.Split:
+			m.layout.Cycle(msg.String() == "shift+tab")
+			m.selected = slices.Index(m.procs, m.layout.Focused().proc)
+
+		case key.Matches(msg, m.help.keys.AddPane) && m.layout.Split:
+			if pane := m.layout.Add(m.procs); pane != nil {
+				pane.terminal.SetHighlight(m.search.Regexp())
+				m.selected = slices.Index(m.procs, pane.proc)
+				m.refresh(0)
+				m.resize()
+			}
+
+		case key.Matches(msg, m.help.keys.RemovePane) && m.layout.Split:
+			if m.layout.Remove() {
+				m.selected = slices.Index(m.procs, m.layout.Focused().proc)
+				m.resize()
+			}
+
+		case key.Matches(msg, m.help.keys.Quit):
+			return m, m.quit()
+
 		case key.Matches(msg, m.help.keys.Up):
-			m.terminal.Viewport.ScrollUp(1)
+			m.active().ScrollUp(1)
 
 		case key.Matches(msg, m.help.keys.Down):
-			m.terminal.Viewport.ScrollDown(1)
+			m.active().ScrollDown(1)
+
+		case key.Matches(msg, m.help.keys.Restart):
+			return m, m.restart(m.procs[m.selected])
+
+		case key.Matches(msg, m.help.keys.RestartAll):
+			return m, m.restart(m.procs...)
+
+		case key.Matches(msg, m.help.keys.RestartFailed):
+			failed := []*proc.Proc{}
+			for _, p := range m.procs {
+				if p.State() == proc.StateError || p.State() == proc.StateCrashLoop {
+					failed = append(failed, p)
+				}
+			}
+
+			return m, m.restart(failed...)
 
 		case key.Matches(msg, m.help.keys.Start):
 			p := m.procs[m.selected]
-			if p.State() == proc.StateRunning {
-				(*m.procs[m.selected]).Stop()
-			} else {
-				(*m.procs[m.selected]).Start(m.ctx)
+			if p.Active() {
+				return m, control(p, "stop", p.Stop)
 			}
+
+			return m, control(p, "start", func() error {
+				return p.Start(m.ctx, proc.TriggerManual)
+			})
+		}
+
+	case controlMsg:
+		// Failures of the process itself are in its logs
+		if errors.Is(msg.err, proc.ErrStarted) || errors.Is(msg.err, proc.ErrNotStarted) {
+			m.help.SetMessage(fmt.Sprintf("%s %s: %s", msg.action, msg.proc.Name, msg.err))
 		}
 
 	case tea.QuitMsg:
 		return m, tea.Quit
 
 	case tea.MouseMsg:
+		// Clicking a proc in the header selects it
+		if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft && m.width != nil {
+			if i := m.header.ItemAt(*m.width, msg.X, msg.Y, m.head()...); i != -1 {
+				m.selected = i
+				m.show()
+			}
+		}
+
 		switch msg.Button {
 		case tea.MouseButtonWheelDown:
-			m.terminal.Viewport.ScrollDown(1)
+			m.active().ScrollDown(1)
 
 		case tea.MouseButtonWheelUp:
-			m.terminal.Viewport.ScrollUp(1)
+			m.active().ScrollUp(1)
+		}
+
+	default:
+		if m.search.Prompting {
+			cmds = append(cmds, m.search.Update(msg))
 		}
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
-func (m Runner) term() (rows []string) {
-	if m.width == nil {
-		return rows
+// updateSearch handles keys while the search prompt is open
+func (m Runner) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "enter":
+		if m.search.Confirm() {
+			m.setHighlight(m.search.Regexp())
+			m.refresh(0)
+			m.find(true)
+		}
+
+	case "esc":
+		m.search.Cancel()
+
+	case "ctrl+r":
+		m.search.ToggleRegex()
+
+	default:
+		return m.search.Update(msg)
+	}
+
+	return nil
+}
+
+// updateAttach forwards keys to the attached proc, until the detach key is pressed
+func (m Runner) updateAttach(msg tea.KeyMsg) {
+	if msg.String() == detach {
+		m.attach.Close()
+		m.terminal.SetAttached(false)
+		return
+	}
+
+	m.attach.Send(msg)
+}
+
+// next waits for the next event from procs
+func (m Runner) next() tea.Msg {
+	e, ok := m.events.Next()
+	if !ok {
+		return nil
+	}
+
+	return e
+}
+
+// controlMsg is the result of starting, stopping or restarting a proc
+type controlMsg struct {
+	proc   *proc.Proc
+	action string
+	err    error
+}
+
+// control runs fn in the background, fn starts or stops p.
+// Starting blocks until the process exits, so that's when the result arrives
+func control(p *proc.Proc, action string, fn func() error) tea.Cmd {
+	return func() tea.Msg {
+		return controlMsg{
+			proc:   p,
+			action: action,
+			err:    fn(),
+		}
+	}
+}
+
+// restart restarts procs, each one running until it stops
+func (m Runner) restart(procs ...*proc.Proc) tea.Cmd {
+	cmds := []tea.Cmd{}
+	for _, p := range procs {
+		cmds = append(cmds, control(p, "restart", func() error {
+			return p.Rerun(m.ctx)
+		}))
+	}
+
+	return tea.Batch(cmds...)
+}
+
+// quit stops every proc at once then quits, quitting again while they stop kills them
+func (m Runner) quit() tea.Cmd {
+	if *m.quitting {
+		return func() tea.Msg {
+			for _, p := range m.procs {
+				p.Kill()
+			}
+
+			return tea.QuitMsg{}
+		}
+	}
+
+	*m.quitting = true
+	m.help.SetMessage("stopping… press q again to kill")
+
+	return func() tea.Msg {
+		var wg sync.WaitGroup
+		for _, p := range m.procs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				p.Stop()
+			}()
+		}
+		wg.Wait()
+
+		return tea.QuitMsg{}
+	}
+}
+
+// show shows the selected proc in the focused pane when split
+func (m Runner) show() {
+	if m.layout.Split {
+		m.layout.Show(m.procs[m.selected])
+		m.layout.Focused().terminal.SetHighlight(m.search.Regexp())
+		m.resize()
+	}
+
+	m.refresh(0)
+}
+
+// Layout returns the layout to save to the settings
+func (m Runner) Layout() settings.Layout {
+	return m.layout.Settings()
+}
+
+// active returns the terminal scrolling and searching applies to, the focused pane's when split
+func (m Runner) active() *Terminal {
+	if m.layout.Split {
+		return m.layout.Focused().terminal
 	}
 
-	for _, msg := range m.msgs {
-		item := []string{}
+	return m.terminal
+}
+
+// setHighlight highlights matches of re in every terminal
+func (m Runner) setHighlight(re *regexp.Regexp) {
+	m.terminal.SetHighlight(re)
+	for _, pane := range m.layout.Panes {
+		pane.terminal.SetHighlight(re)
+	}
+}
 
-		switch msg.State {
-		case app.StateLoading:
-			item = append(item, m.spinner.View())
-		case app.StateSuccess:
-			item = append(item, checkmark)
-		case app.StateError:
-			item = append(item, xmark)
+// refresh gives every terminal the rows of the feed items from index from, replacing all of their rows when from is 0
+func (m Runner) refresh(from int) {
+	set := func(t *Terminal, rows []*Row) {
+		if from == 0 {
+			t.SetRows(rows)
+		} else {
+			t.Append(rows...)
 		}
+	}
 
-		item = append(item, msg.Text)
-		itemStr := strings.Join(item, " ")
+	set(m.terminal, m.rows(from))
+	for _, pane := range m.layout.Panes {
+		set(pane.terminal, m.paneRows(pane.proc, from))
+	}
+}
 
-		// Render the row
-		rows = append(rows, m.terminal.GenItem(msg.Time, msg.AppName, itemStr, msg.AppColor, *m.width))
+// find jumps to the next or previous match of the search
+func (m Runner) find(backwards bool) {
+	if m.search.Regexp() == nil {
+		return
+	}
+
+	if m.active().Find(backwards) {
+		m.search.SetMessage("")
+	} else {
+		m.search.SetMessage("no matches")
+	}
+}
+
+// rows returns the terminal rows of the feed items from index from, that pass the filters
+func (m Runner) rows(from int) []*Row {
+	rows := []*Row{}
+	for _, item := range m.feed.items[from:] {
+		if m.single && item.proc != m.procs[m.selected] {
+			continue
+		}
+		if !m.single && m.hidden[item.proc] {
+			continue
+		}
+		if m.search.Filter && !m.search.Match(item.row.Plain) {
+			continue
+		}
+
+		rows = append(rows, item.row)
 	}
 
 	return rows
 }
 
+// paneRows returns the terminal rows of p's feed items from index from, that pass the search filter
+func (m Runner) paneRows(p *proc.Proc, from int) []*Row {
+	rows := []*Row{}
+	for _, item := range m.feed.items[from:] {
+		if item.proc != p {
+			continue
+		}
+		if m.search.Filter && !m.search.Match(item.row.Plain) {
+			continue
+		}
+
+		rows = append(rows, item.row)
+	}
+
+	return rows
+}
+
+// export writes the view as plain text to a file, or to the clipboard.
+// With lastRun, only the last run of the selected proc is exported
+func (m Runner) export(lastRun bool, clipboard bool) {
+	name := "view"
+	rows := []*Row{}
+	switch {
+	case lastRun:
+		p := m.procs[m.selected]
+		name = p.Name
+
+		run := 0
+		for _, item := range m.feed.items {
+			if item.proc == p {
+				run = max(run, item.run)
+			}
+		}
+
+		for _, item := range m.feed.items {
+			if item.proc != p || item.run != run {
+				continue
+			}
+			if m.search.Filter && !m.search.Match(item.row.Plain) {
+				continue
+			}
+
+			rows = append(rows, item.row)
+		}
+
+	case m.layout.Split:
+		name = m.layout.Focused().proc.Name
+		rows = m.paneRows(m.layout.Focused().proc, 0)
+
+	default:
+		rows = m.rows(0)
+	}
+
+	if len(rows) == 0 {
+		m.help.SetMessage("nothing to export")
+		return
+	}
+	text := exportText(rows)
+
+	if clipboard {
+		if err := copyText(text); err != nil {
+			m.help.SetMessage(fmt.Sprintf("copy: %s", err))
+			return
+		}
+
+		m.help.SetInfo(fmt.Sprintf("copied %d lines", len(rows)))
+		return
+	}
+
+	path, err := exportFile(m.dir, name, text)
+	if err != nil {
+		m.help.SetMessage(fmt.Sprintf("export: %s", err))
+		return
+	}
+	if rel, err := filepath.Rel(m.dir, path); err == nil {
+		path = rel
+	}
+
+	m.help.SetInfo(fmt.Sprintf("exported %d lines to %s", len(rows), path))
+}
+
+// resize sizes the pseudo-terminals of procs to fit the text of the terminal, or their panes
+func (m Runner) resize() {
+	if m.width == nil || m.height == nil {
+		return
+	}
+
+	header := m.header.Gen(*m.width, m.head()...)
+	footer := m.help.Gen(*m.width)
+	cols, rows := m.terminal.Size(*m.width, *m.height, lipgloss.Height(header), lipgloss.Height(footer))
+
+	sizes := map[*proc.Proc]box{}
+	if m.layout.Split {
+		sizes = m.layout.Sizes(*m.width, rows)
+	}
+
+	for _, p := range m.procs {
+		if size, ok := sizes[p]; ok {
+			p.Resize(size.width, size.height)
+		} else {
+			p.Resize(cols, rows)
+		}
+	}
+}
+
+// icon returns the icon for the state of p
+func (m Runner) icon(p *proc.Proc) string {
+	switch p.State() {
+	case proc.StateRunning, proc.StateStarting, proc.StateStopping:
+		return m.spinner.View()
+	case proc.StateSuccess:
+		return checkmark
+	case proc.StateError:
+		return xmark
+	case proc.StateCrashLoop:
+		return crashloop
+	case proc.StateQueued:
+		return queued
+	default:
+		return pause
+	}
+}
+
 func (m Runner) head() (items []string) {
-	for _, a := range m.apps {
-		item := []string{}
+	for i, p := range m.procs {
+		name := p.Name
+		if m.hidden[p] {
+			name = hidden.Render(name)
+		}
 
-		switch a.State {
-		case app.StateLoading:
-			item = append(item, m.spinner.View())
-		case app.StateSuccess:
-			item = append(item, checkmark)
-		case app.StateError:
-			item = append(item, xmark)
-		case app.StatePause:
-			item = append(item, pause)
+		// Show what's happening while it's waiting, starting or stopping
+		switch p.State() {
+		case proc.StateQueued, proc.StateStarting, proc.StateStopping:
+			name += " " + transition.Render(p.State().String()+"…")
 		}
 
-		item = append(item, (*a).Name)
-		items = append(items, m.header.GenItem(strings.Join(item, " ")))
+		items = append(items, m.header.GenItem(m.icon(p)+" "+name, i == m.selected))
 	}
 
 	return items
 }
 
+// title returns the title of a pane showing p
+func (m Runner) title(p *proc.Proc) string {
+	return m.icon(p) + " " + p.Name + " " + p.State().String()
+}
+
 func (m Runner) View() string {
 	if m.width == nil || m.height == nil {
 		return fmt.Sprintf("\n %s Loading...", m.spinner.View())
@@ -182,21 +656,42 @@ func (m Runner) View() string {
 
 	// Generate the UI
 	header := m.header.Gen(*m.width, m.head()...)
-	footer := m.help.Gen(*m.width)
-	main := m.terminal.Gen(
-		strings.Join(m.term(), "\n"),
-		*m.width,
-		*m.height,
-		lipgloss.Height(header),
-		lipgloss.Height(footer),
-	)
+	details := m.details.Gen(m.procs[m.selected], *m.width)
+	footer := m.attach.Gen(*m.width)
+	if footer == "" {
+		footer = m.search.Gen(*m.width)
+	}
+	if footer == "" {
+		footer = m.help.Gen(*m.width)
+	}
+
+	// The top margin of details shares the last line of the header
+	mtop := lipgloss.Height(header)
+	if details != "" {
+		mtop += lipgloss.Height(details) - 1
+	}
+
+	var main string
+	if m.layout.Split {
+		// Panes go inside the frame of the terminal
+		_, rows := m.terminal.Size(*m.width, *m.height, mtop, lipgloss.Height(footer))
+		if rows > 0 {
+			main = m.terminal.style.Width(*m.width).Render(m.layout.Gen(*m.width, rows, m.title))
+		}
+	} else {
+		main = m.terminal.Gen(
+			*m.width,
+			*m.height,
+			mtop,
+			lipgloss.Height(footer),
+		)
+	}
 
 	s := header
+	s += details
 	s += main
 	s += footer
 
-	os.WriteFile("/tmp/teatest", []byte(s), 0644)
-
 	// Send the UI for rendering
 	return s
 }
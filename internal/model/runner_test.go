@@ -0,0 +1,220 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/exp/teatest"
+	"github.com/spotdemo4/treli/internal/proc"
+	"github.com/spotdemo4/treli/internal/settings"
+)
+
+const (
+	testWidth  = 120
+	testHeight = 30
+)
+
+// task returns a task that prints "<name> run <n>" on its nth run and exits with code, after running it once
+func task(t *testing.T, bus *proc.Bus, name string, code int) *proc.Proc {
+	t.Helper()
+
+	p := proc.New(name, proc.Options{
+		Color:   "#89b4fa",
+		Kind:    proc.KindTask,
+		OnStart: fmt.Sprintf(`n=$(($(cat n 2>/dev/null || echo 0) + 1)); echo $n > n; echo "%s run $n"; exit %d`, name, code),
+		Dir:     t.TempDir(),
+	}, bus)
+	if err := p.Start(context.Background(), proc.TriggerManual); err != nil && code == 0 {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// start runs a runner for procs in a test program
+func start(t *testing.T, procs []*proc.Proc, bus *proc.Bus) *teatest.TestModel {
+	t.Helper()
+
+	return teatest.NewTestModel(t,
+		NewRunner(context.Background(), procs, bus, settings.Layout{}, t.TempDir()),
+		teatest.WithInitialTermSize(testWidth, testHeight),
+	)
+}
+
+// waitFor waits until the output, without escape sequences, contains every text
+func waitFor(t *testing.T, tm *teatest.TestModel, texts ...string) {
+	t.Helper()
+
+	teatest.WaitFor(t, tm.Output(), func(b []byte) bool {
+		out := ansi.Strip(string(b))
+		for _, text := range texts {
+			if !strings.Contains(out, text) {
+				return false
+			}
+		}
+
+		return true
+	}, teatest.WithDuration(5*time.Second))
+}
+
+// quit quits the runner, returning the model it ended with
+func quit(t *testing.T, tm *teatest.TestModel) Runner {
+	t.Helper()
+
+	tm.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	return tm.FinalModel(t, teatest.WithFinalTimeout(5*time.Second)).(Runner)
+}
+
+// runs returns how many times each proc ran
+func runs(procs []*proc.Proc) []int {
+	n := []int{}
+	for _, p := range procs {
+		n = append(n, len(p.History()))
+	}
+
+	return n
+}
+
+func TestRunnerRestart(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want []int
+		out  []string
+	}{
+		{
+			name: "selected",
+			keys: []string{"r"},
+			want: []int{2, 1, 1},
+			out:  []string{"alpha run 2"},
+		},
+		{
+			name: "selected after moving",
+			keys: []string{"l", "r"},
+			want: []int{1, 2, 1},
+			out:  []string{"bravo run 2"},
+		},
+		{
+			name: "all",
+			keys: []string{"R"},
+			want: []int{2, 2, 2},
+			out:  []string{"alpha run 2", "bravo run 2", "charlie run 2"},
+		},
+		{
+			name: "failed",
+			keys: []string{"F"},
+			want: []int{1, 2, 1},
+			out:  []string{"bravo run 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := proc.NewBus()
+			defer bus.Close()
+
+			procs := []*proc.Proc{
+				task(t, bus, "alpha", 0),
+				task(t, bus, "bravo", 1),
+				task(t, bus, "charlie", 0),
+			}
+
+			tm := start(t, procs, bus)
+			waitFor(t, tm, "charlie")
+
+			for _, k := range tt.keys {
+				tm.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+			}
+			waitFor(t, tm, tt.out...)
+
+			quit(t, tm)
+
+			got := runs(procs)
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("runs = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunnerHeaderClick(t *testing.T) {
+	bus := proc.NewBus()
+	defer bus.Close()
+
+	procs := []*proc.Proc{
+		task(t, bus, "alpha", 0),
+		task(t, bus, "bravo", 0),
+		task(t, bus, "charlie", 0),
+	}
+
+	// Find where bravo is in the header
+	var m tea.Model = NewRunner(context.Background(), procs, bus, settings.Layout{}, t.TempDir())
+	m, _ = m.Update(tea.WindowSizeMsg{Width: testWidth, Height: testHeight})
+	x, y := -1, -1
+	for i, line := range strings.Split(ansi.Strip(m.View()), "\n") {
+		if col := strings.Index(line, "bravo"); col != -1 {
+			x, y = ansi.StringWidth(line[:col]), i
+			break
+		}
+	}
+	if x == -1 {
+		t.Fatal("bravo isn't in the header")
+	}
+
+	tests := []struct {
+		name string
+		x    int
+		y    int
+		want int
+	}{
+		{name: "item", x: x, y: y, want: 1},
+		{name: "end of item", x: x + len("bravo") - 1, y: y, want: 1},
+		{name: "outside header", x: x, y: testHeight - 2, want: 0},
+		{name: "left of items", x: 0, y: y, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := start(t, procs, bus)
+			waitFor(t, tm, "charlie")
+
+			tm.Send(tea.MouseMsg{X: tt.x, Y: tt.y, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft})
+
+			// The run history is of the selected proc
+			tm.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+			waitFor(t, tm, procs[tt.want].Name+" runs")
+
+			if got := quit(t, tm).selected; got != tt.want {
+				t.Errorf("selected = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunnerQuit(t *testing.T) {
+	bus := proc.NewBus()
+	defer bus.Close()
+
+	p := proc.New("sleeper", proc.Options{
+		Color:   "#89b4fa",
+		OnStart: "echo started; sleep 60 && echo done",
+		Dir:     t.TempDir(),
+	}, bus)
+	go p.Start(context.Background(), proc.TriggerManual)
+
+	tm := start(t, []*proc.Proc{p}, bus)
+	waitFor(t, tm, "started")
+
+	quit(t, tm)
+
+	if p.Active() {
+		t.Error("proc is still running after quitting")
+	}
+}
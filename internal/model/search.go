@@ -0,0 +1,169 @@
+package model
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type Search struct {
+	style      lipgloss.Style
+	labelStyle lipgloss.Style
+	errStyle   lipgloss.Style
+	input      textinput.Model
+
+	// Whether the prompt is open
+	Prompting bool
+	// Whether the query is a regular expression
+	Regex bool
+	// Whether non-matching rows are hidden
+	Filter bool
+
+	re  *regexp.Regexp
+	err error
+	msg string
+}
+
+func NewSearch() *Search {
+	input := textinput.New()
+	input.Prompt = "/"
+	input.PromptStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#89b4fa"))
+	input.TextStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#cdd6f4"))
+
+	return &Search{
+		style:      lipgloss.NewStyle().Padding(1, 2).AlignVertical(lipgloss.Bottom).Height(1),
+		labelStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("#a6adc8")),
+		errStyle:   lipgloss.NewStyle().Foreground(lipgloss.Color("#f38ba8")),
+		input:      input,
+	}
+}
+
+// Open shows the prompt, editing the current query
+func (s *Search) Open() tea.Cmd {
+	s.Prompting = true
+	s.err = nil
+	s.msg = ""
+	s.input.CursorEnd()
+
+	return s.input.Focus()
+}
+
+// Confirm closes the prompt and compiles the query, returns whether the query is valid
+func (s *Search) Confirm() bool {
+	query := s.input.Value()
+	if query == "" {
+		s.Clear()
+		return true
+	}
+
+	if !s.Regex {
+		query = regexp.QuoteMeta(query)
+	}
+
+	// Smart case, only match case when the query has upper case letters
+	if strings.ToLower(query) == query {
+		query = "(?i)" + query
+	}
+
+	re, err := regexp.Compile(query)
+	if err != nil {
+		s.err = err
+		return false
+	}
+
+	s.re = re
+	s.err = nil
+	s.Prompting = false
+	s.input.Blur()
+
+	return true
+}
+
+// Cancel closes the prompt, keeping the previous query
+func (s *Search) Cancel() {
+	s.Prompting = false
+	s.err = nil
+	s.input.Blur()
+
+	if s.re == nil {
+		s.input.Reset()
+	}
+}
+
+// Clear removes the query
+func (s *Search) Clear() {
+	s.Prompting = false
+	s.Filter = false
+	s.re = nil
+	s.err = nil
+	s.msg = ""
+	s.input.Blur()
+	s.input.Reset()
+}
+
+func (s *Search) ToggleRegex() {
+	s.Regex = !s.Regex
+	s.err = nil
+}
+
+func (s *Search) ToggleFilter() {
+	s.Filter = !s.Filter
+}
+
+// Regexp returns the compiled query, nil if there isn't one
+func (s *Search) Regexp() *regexp.Regexp {
+	return s.re
+}
+
+// Match returns whether text matches the query, everything matches without one
+func (s *Search) Match(text string) bool {
+	return s.re == nil || s.re.MatchString(text)
+}
+
+// SetMessage shows a message next to the query, like when nothing matched
+func (s *Search) SetMessage(msg string) {
+	s.msg = msg
+}
+
+func (s *Search) Update(msg tea.Msg) tea.Cmd {
+	var cmd tea.Cmd
+	s.input, cmd = s.input.Update(msg)
+	s.err = nil
+
+	return cmd
+}
+
+// Gen renders the prompt, or the current query, empty if there's neither
+func (s *Search) Gen(width int) string {
+	if !s.Prompting && s.re == nil {
+		return ""
+	}
+
+	mode := "text"
+	if s.Regex {
+		mode = "regex"
+	}
+
+	items := []string{}
+	if s.Prompting {
+		s.input.Width = max(width-40, 10)
+		items = append(items, s.input.View(), s.labelStyle.Render("["+mode+"] ctrl+r toggle regex"))
+	} else {
+		items = append(items, s.input.PromptStyle.Render("/")+s.input.TextStyle.Render(s.input.Value()), s.labelStyle.Render("["+mode+"]"))
+		if s.Filter {
+			items = append(items, s.labelStyle.Render("[filter]"))
+		}
+		items = append(items, s.labelStyle.Render("n/N next/prev • f filter • esc clear"))
+	}
+
+	if s.err != nil {
+		items = append(items, s.errStyle.Render(s.err.Error()))
+	} else if s.msg != "" {
+		items = append(items, s.errStyle.Render(s.msg))
+	}
+
+	return s.style.Width(width).Render(strings.Join(items, " "))
+}
@@ -1,16 +1,62 @@
 package model
 
 import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/spotdemo4/treli/internal/util"
 )
 
+// Row is a single item in the terminal, rendered lazily and cached until the width changes
+type Row struct {
+	Time   time.Time
+	Prefix string
+	Text   string
+	Plain  string
+	Color  string
+
+	lines     []string
+	width     int
+	highlight *regexp.Regexp
+}
+
+// update changes the text of the row, so it's rendered again
+func (r *Row) update(text string) {
+	if text == r.Text {
+		return
+	}
+
+	r.Text = text
+	r.Plain = util.StripANSI(text)
+	r.width = 0
+}
+
 type Terminal struct {
 	style        lipgloss.Style
-	Viewport     *viewport.Model
+	timeStyle    lipgloss.Style
+	textStyle    lipgloss.Style
+	matchStyle   lipgloss.Style
+	currentStyle lipgloss.Style
+	prefixStyles map[string]lipgloss.Style
 	maxPrefixLen int
+
+	width  int
+	height int
+
+	// Position of the first visible line, the row and how many of its lines are scrolled past.
+	// While following, the view sticks to the bottom instead
+	rows   []*Row
+	top    int
+	skip   int
+	follow bool
+
+	// Search matches are highlighted, match is the row last jumped to
+	highlight *regexp.Regexp
+	match     *Row
 }
 
 func NewTerminal(maxPrefixLen int) *Terminal {
@@ -22,51 +68,322 @@ func NewTerminal(maxPrefixLen int) *Terminal {
 		Margin(1, 0, 0)
 
 	return &Terminal{
-		style:        s,
-		Viewport:     nil,
+		style: s,
+		timeStyle: lipgloss.NewStyle().
+			Padding(0, 1, 0, 1).
+			Foreground(lipgloss.Color("#a6adc8")),
+		textStyle: lipgloss.NewStyle().
+			Padding(0, 1, 0, 1).
+			Foreground(lipgloss.Color("#cdd6f4")),
+		matchStyle: lipgloss.NewStyle().
+			Background(lipgloss.Color("#f9e2af")).
+			Foreground(lipgloss.Color("#11111b")),
+		currentStyle: lipgloss.NewStyle().
+			Background(lipgloss.Color("#fab387")).
+			Foreground(lipgloss.Color("#11111b")).
+			Bold(true),
+		prefixStyles: map[string]lipgloss.Style{},
 		maxPrefixLen: maxPrefixLen,
+
+		rows:   []*Row{},
+		follow: true,
+	}
+}
+
+// SetRows replaces every row, keeping the scroll position when possible
+func (c *Terminal) SetRows(rows []*Row) {
+	if !c.follow && c.top < len(c.rows) {
+		top := c.rows[c.top]
+
+		c.top = slices.Index(rows, top)
+		if c.top == -1 {
+			c.top = sort.Search(len(rows), func(i int) bool {
+				return !rows[i].Time.Before(top.Time)
+			})
+			c.skip = 0
+		}
+	}
+
+	c.rows = rows
+}
+
+// Append adds rows to the bottom
+func (c *Terminal) Append(rows ...*Row) {
+	c.rows = append(c.rows, rows...)
+}
+
+func (c *Terminal) ScrollUp(n int) {
+	if c.height <= 0 {
+		return
+	}
+	if c.follow {
+		c.top, c.skip = c.bottom()
+		c.follow = false
+	}
+
+	for n > 0 {
+		if c.skip >= n {
+			c.skip -= n
+			return
+		}
+
+		n -= c.skip
+		c.skip = 0
+		if c.top == 0 {
+			return
+		}
+
+		c.top--
+		c.skip = len(c.lines(c.rows[c.top]))
 	}
 }
 
-func (c *Terminal) Gen(text string, width int, height int, mtop int, mbottom int) string {
-	if c.Viewport == nil {
-		vp := viewport.New(width, height-(mtop+mbottom)+2)
-		c.Viewport = &vp
-		c.Viewport.YPosition = mtop
-		c.Viewport.Style = c.style
-	} else {
-		c.Viewport.Width = width
-		c.Viewport.Height = height - (mtop + mbottom) + 2
-		c.Viewport.YPosition = mtop
+func (c *Terminal) ScrollDown(n int) {
+	if c.height <= 0 || c.follow {
+		return
+	}
+
+	for n > 0 && c.top < len(c.rows) {
+		l := len(c.lines(c.rows[c.top]))
+		if c.skip+n < l {
+			c.skip += n
+			break
+		}
+
+		n -= l - c.skip
+		c.top++
+		c.skip = 0
+	}
+
+	// Stick to the bottom once it's reached
+	top, skip := c.bottom()
+	if c.top > top || (c.top == top && c.skip >= skip) {
+		c.follow = true
 	}
+}
+
+func (c *Terminal) GotoBottom() {
+	c.follow = true
+}
 
-	atBottom := c.Viewport.AtBottom()
-	c.Viewport.SetContent(text)
+func (c *Terminal) AtBottom() bool {
+	return c.follow
+}
 
-	if atBottom {
-		c.Viewport.GotoBottom()
+// SetAttached colors the border while input is forwarded to a proc
+func (c *Terminal) SetAttached(attached bool) {
+	color := lipgloss.Color("#45475a")
+	if attached {
+		color = lipgloss.Color("#fab387")
 	}
 
-	return c.Viewport.View()
+	c.style = c.style.BorderForeground(color)
+}
+
+// SetHighlight highlights matches of re, nil removes highlighting
+func (c *Terminal) SetHighlight(re *regexp.Regexp) {
+	c.highlight = re
+	c.match = nil
+}
+
+// Find scrolls to the next row matching the highlight, or the previous one when backwards.
+// Returns false if no row matches
+func (c *Terminal) Find(backwards bool) bool {
+	if c.highlight == nil || len(c.rows) == 0 {
+		return false
+	}
+
+	n := len(c.rows)
+
+	// Search from the last match, or the top of the view.
+	// When following, search backwards from the bottom and forwards from the start
+	cur := slices.Index(c.rows, c.match)
+	if cur == -1 {
+		switch {
+		case c.follow && backwards:
+			cur = n
+		case c.follow:
+			cur = -1
+		case backwards:
+			cur = c.top
+		default:
+			cur = c.top - 1
+		}
+	}
+
+	for step := 1; step <= n; step++ {
+		i := ((cur+step)%n + n) % n
+		if backwards {
+			i = ((cur-step)%n + n) % n
+		}
+
+		if !c.highlight.MatchString(c.rows[i].Plain) {
+			continue
+		}
+
+		// Re-render the previous and new match
+		if c.match != nil {
+			c.match.width = 0
+		}
+		c.match = c.rows[i]
+		c.match.width = 0
+
+		// Center the match in the view
+		c.top = i
+		c.skip = 0
+		c.follow = false
+		c.ScrollUp(c.height / 2)
+
+		return true
+	}
+
+	return false
+}
+
+// bottom returns the position of the first visible line when scrolled to the bottom
+func (c *Terminal) bottom() (top int, skip int) {
+	need := c.height
+	for i := len(c.rows) - 1; i >= 0; i-- {
+		n := len(c.lines(c.rows[i]))
+		if n >= need {
+			return i, n - need
+		}
+
+		need -= n
+	}
+
+	return 0, 0
+}
+
+// lines returns the rendered lines of a row, rendering it if the width changed
+func (c *Terminal) lines(r *Row) []string {
+	if r.width != c.width || r.highlight != c.highlight {
+		// Matches are highlighted on the text without colors
+		text := r.Text
+		if c.highlight != nil && c.highlight.MatchString(r.Plain) {
+			text = c.highlightText(r.Plain, r == c.match)
+		}
+
+		r.lines = strings.Split(c.GenItem(r.Time, r.Prefix, text, r.Color, c.width), "\n")
+		r.width = c.width
+		r.highlight = c.highlight
+	}
+
+	return r.lines
+}
+
+// highlightText styles every match of the highlight in text
+func (c *Terminal) highlightText(text string, current bool) string {
+	matches := c.highlight.FindAllStringIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	style := c.matchStyle
+	if current {
+		style = c.currentStyle
+	}
+
+	// Style the text between matches too, so the matches don't reset its color
+	plain := lipgloss.NewStyle().Foreground(c.textStyle.GetForeground())
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m[0] == m[1] {
+			continue
+		}
+
+		sb.WriteString(plain.Render(text[last:m[0]]))
+		sb.WriteString(style.Render(text[m[0]:m[1]]))
+		last = m[1]
+	}
+	sb.WriteString(plain.Render(text[last:]))
+
+	return sb.String()
+}
+
+// visible returns the lines shown from the given position
+func (c *Terminal) visible(top int, skip int) []string {
+	lines := []string{}
+	for i := top; i < len(c.rows) && len(lines) < c.height; i++ {
+		l := c.lines(c.rows[i])
+		if i == top {
+			l = l[min(skip, len(l)):]
+		}
+
+		lines = append(lines, l...)
+	}
+
+	return lines
+}
+
+// Size returns the columns and rows available to the text of rows
+func (c *Terminal) Size(width int, height int, mtop int, mbottom int) (int, int) {
+	return c.cols(width), height - (mtop + mbottom) + 2 - c.style.GetVerticalFrameSize()
+}
+
+// cols returns the columns available to the text of rows, when the terminal is width wide
+func (c *Terminal) cols(width int) int {
+	time := c.timeStyle.GetHorizontalFrameSize() + len("12:00PM")
+	prefix := c.maxPrefixLen + 1
+	text := c.textStyle.GetHorizontalFrameSize()
+
+	return width - time - prefix - text
+}
+
+func (c *Terminal) Gen(width int, height int, mtop int, mbottom int) string {
+	_, rows := c.Size(width, height, mtop, mbottom)
+	return c.render(width, rows)
+}
+
+// render renders the visible lines, height is the number of lines inside the frame
+func (c *Terminal) render(width int, height int) string {
+	c.width = width
+	c.height = height
+	if c.height <= 0 {
+		return ""
+	}
+
+	// Only render the rows that are visible
+	var lines []string
+	if !c.follow {
+		lines = c.visible(c.top, c.skip)
+
+		// Not enough left to fill the view, so we're at the bottom
+		if len(lines) < c.height {
+			c.follow = true
+		}
+	}
+	if c.follow {
+		lines = c.visible(c.bottom())
+	}
+	if len(lines) > c.height {
+		lines = lines[:c.height]
+	}
+
+	return c.style.
+		Width(width).
+		Height(c.height).
+		Render(strings.Join(lines, "\n"))
 }
 
 func (c *Terminal) GenItem(ti time.Time, prefix string, text string, color string, width int) string {
-	t := lipgloss.NewStyle().
-		Padding(0, 1, 0, 1).
-		Foreground(lipgloss.Color("#a6adc8")).
-		Render(ti.Format(time.Kitchen))
-
-	p := lipgloss.NewStyle().
-		Padding(0, 1, 0, 0).
-		Width(c.maxPrefixLen).
-		Foreground(lipgloss.Color(color)).
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderRight(true).
-		BorderForeground(lipgloss.Color(color))
+	t := c.timeStyle.Render(ti.Format(time.Kitchen))
+
+	p, ok := c.prefixStyles[color]
+	if !ok {
+		p = lipgloss.NewStyle().
+			Padding(0, 1, 0, 0).
+			Width(c.maxPrefixLen).
+			Foreground(lipgloss.Color(color)).
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderRight(true).
+			BorderForeground(lipgloss.Color(color))
+		c.prefixStyles[color] = p
+	}
 
-	m := lipgloss.NewStyle().
-		Padding(0, 1, 0, 1).
-		Foreground(lipgloss.Color("#cdd6f4")).
+	m := c.textStyle.
 		Width(width - lipgloss.Width(t) - lipgloss.Width(p.Render(prefix))).
 		Render(text)
 
@@ -0,0 +1,212 @@
+package proc
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event is something that happened to a proc
+type Event interface {
+	ProcID() uuid.UUID
+}
+
+// LogAppended is sent for every line of output. A partial line is sent again, with the same index, every time it's overwritten
+type LogAppended struct {
+	Proc  uuid.UUID
+	Index int
+	Line  Line
+}
+
+// StateChanged is sent when the state of a proc changes
+type StateChanged struct {
+	Proc  uuid.UUID
+	State State
+}
+
+// Triggered is sent when a run starts
+type Triggered struct {
+	Proc    uuid.UUID
+	Run     int
+	Trigger Trigger
+	Variant Variant
+}
+
+// RunFinished is sent when a run ends, after it's added to the history
+type RunFinished struct {
+	Proc uuid.UUID
+	Run  Run
+}
+
+// ConfigReloaded is sent when the config file was changed and read again, Err is why it's invalid.
+// Procs keep running with the config they were started with
+type ConfigReloaded struct {
+	File string
+	Err  error
+}
+
+func (e LogAppended) ProcID() uuid.UUID    { return e.Proc }
+func (e StateChanged) ProcID() uuid.UUID   { return e.Proc }
+func (e Triggered) ProcID() uuid.UUID      { return e.Proc }
+func (e RunFinished) ProcID() uuid.UUID    { return e.Proc }
+func (e ConfigReloaded) ProcID() uuid.UUID { return uuid.Nil }
+
+// Policy is what happens when a subscriber falls behind
+type Policy int
+
+const (
+	// Block makes publishers wait until the subscriber has room, so nothing is lost.
+	// Procs wait too, and stop reading their output
+	Block Policy = iota
+
+	// Coalesce replaces a queued event with a newer one of the same type for the same proc once the queue is full,
+	// so it grows past its size by at most one event per type and proc, and publishing never waits.
+	// For subscribers that only need to know something changed
+	Coalesce
+)
+
+// Bus delivers the events of procs to every subscriber.
+// Every subscriber gets events in the order they were published, even from different goroutines
+type Bus struct {
+	subs []*Subscription
+	mu   *sync.Mutex
+
+	// Held while an event is delivered, so the next one waits for it
+	publish *sync.Mutex
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs:    []*Subscription{},
+		mu:      &sync.Mutex{},
+		publish: &sync.Mutex{},
+	}
+}
+
+// Subscribe registers a subscriber, size is how many events can be queued before the policy applies
+func (b *Bus) Subscribe(policy Policy, size int) *Subscription {
+	s := &Subscription{
+		bus:    b,
+		policy: policy,
+		size:   max(size, 1),
+		queue:  []Event{},
+		mu:     &sync.Mutex{},
+	}
+	s.cond = sync.NewCond(s.mu)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs = append(b.subs, s)
+
+	return s
+}
+
+// Publish sends e to every subscriber
+func (b *Bus) Publish(e Event) {
+	b.publish.Lock()
+	defer b.publish.Unlock()
+
+	b.mu.Lock()
+	subs := slices.Clone(b.subs)
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.push(e)
+	}
+}
+
+// Close closes every subscription
+func (b *Bus) Close() {
+	b.mu.Lock()
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		s.close()
+	}
+}
+
+// Subscription is the queue of events for a subscriber
+type Subscription struct {
+	bus    *Bus
+	policy Policy
+	size   int
+
+	queue  []Event
+	closed bool
+	mu     *sync.Mutex
+	cond   *sync.Cond
+}
+
+// Next waits for the next event, returns false once the subscription is closed
+func (s *Subscription) Next() (Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.queue) == 0 && !s.closed {
+		s.cond.Wait()
+	}
+	if len(s.queue) == 0 {
+		return nil, false
+	}
+
+	e := s.queue[0]
+	s.queue = s.queue[1:]
+
+	// Blocked publishers can continue
+	s.cond.Broadcast()
+
+	return e, true
+}
+
+// Close unsubscribes, events that are queued can still be read
+func (s *Subscription) Close() {
+	// Closing first lets a publisher waiting for room continue
+	s.close()
+
+	s.bus.mu.Lock()
+	s.bus.subs = slices.DeleteFunc(s.bus.subs, func(sub *Subscription) bool {
+		return sub == s
+	})
+	s.bus.mu.Unlock()
+}
+
+func (s *Subscription) push(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.policy == Coalesce && len(s.queue) >= s.size:
+		// The latest one, so events of a type and proc stay in order
+		for i := len(s.queue) - 1; i >= 0; i-- {
+			if reflect.TypeOf(s.queue[i]) == reflect.TypeOf(e) && s.queue[i].ProcID() == e.ProcID() {
+				s.queue[i] = e
+				return
+			}
+		}
+
+	case s.policy == Block:
+		for len(s.queue) >= s.size && !s.closed {
+			s.cond.Wait()
+		}
+	}
+
+	if s.closed {
+		return
+	}
+
+	s.queue = append(s.queue, e)
+	s.cond.Broadcast()
+}
+
+func (s *Subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.cond.Broadcast()
+}
@@ -0,0 +1,258 @@
+package proc
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// published publishes e in the background, the returned channel is closed once Publish returns
+func published(b *Bus, e Event) chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Publish(e)
+	}()
+
+	return done
+}
+
+// returned reports whether done is closed within a short time
+func returned(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	case <-time.After(50 * time.Millisecond):
+		return false
+	}
+}
+
+// drain reads every queued event of a closed subscription
+func drain(s *Subscription) []Event {
+	events := []Event{}
+	for {
+		e, ok := s.Next()
+		if !ok {
+			return events
+		}
+		events = append(events, e)
+	}
+}
+
+func TestBusBlock(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(Block, 2)
+	id := uuid.New()
+
+	b.Publish(StateChanged{Proc: id, State: StateStarting})
+	b.Publish(StateChanged{Proc: id, State: StateRunning})
+
+	// The queue is full, so the publisher waits
+	done := published(b, StateChanged{Proc: id, State: StateSuccess})
+	if returned(done) {
+		t.Fatal("Publish() returned while the queue was full")
+	}
+
+	// Reading one makes room
+	if e, _ := s.Next(); e.(StateChanged).State != StateStarting {
+		t.Errorf("Next() = %v, want the first event", e)
+	}
+	if !returned(done) {
+		t.Fatal("Publish() is still waiting after an event was read")
+	}
+
+	s.Close()
+	want := []Event{
+		StateChanged{Proc: id, State: StateRunning},
+		StateChanged{Proc: id, State: StateSuccess},
+	}
+	if got := drain(s); !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestBusBlockClose(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(Block, 1)
+
+	b.Publish(StateChanged{State: StateRunning})
+	done := published(b, StateChanged{State: StateSuccess})
+	if returned(done) {
+		t.Fatal("Publish() returned while the queue was full")
+	}
+
+	// Unsubscribing lets the publisher continue, dropping the event
+	s.Close()
+	if !returned(done) {
+		t.Fatal("Publish() is still waiting after the subscription was closed")
+	}
+	if got := drain(s); len(got) != 1 {
+		t.Errorf("events = %v, want only the queued one", got)
+	}
+
+	// Later events aren't queued
+	b.Publish(StateChanged{State: StateError})
+	if got := drain(s); len(got) != 0 {
+		t.Errorf("events = %v after closing, want none", got)
+	}
+}
+
+func TestBusCoalesce(t *testing.T) {
+	a, c := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name    string
+		size    int
+		publish []Event
+		want    []Event
+	}{
+		{
+			name: "room",
+			size: 3,
+			publish: []Event{
+				StateChanged{Proc: a, State: StateStarting},
+				StateChanged{Proc: a, State: StateRunning},
+			},
+			want: []Event{
+				StateChanged{Proc: a, State: StateStarting},
+				StateChanged{Proc: a, State: StateRunning},
+			},
+		},
+		{
+			name: "replaces same type and proc",
+			size: 1,
+			publish: []Event{
+				StateChanged{Proc: a, State: StateStarting},
+				StateChanged{Proc: a, State: StateRunning},
+				StateChanged{Proc: a, State: StateSuccess},
+			},
+			want: []Event{
+				StateChanged{Proc: a, State: StateSuccess},
+			},
+		},
+		{
+			name: "keeps other procs",
+			size: 1,
+			publish: []Event{
+				StateChanged{Proc: a, State: StateRunning},
+				StateChanged{Proc: c, State: StateRunning},
+				StateChanged{Proc: a, State: StateSuccess},
+				StateChanged{Proc: c, State: StateError},
+			},
+			want: []Event{
+				StateChanged{Proc: a, State: StateSuccess},
+				StateChanged{Proc: c, State: StateError},
+			},
+		},
+		{
+			name: "keeps other types",
+			size: 1,
+			publish: []Event{
+				LogAppended{Proc: a, Index: 0},
+				StateChanged{Proc: a, State: StateRunning},
+				LogAppended{Proc: a, Index: 1},
+				StateChanged{Proc: a, State: StateSuccess},
+			},
+			want: []Event{
+				LogAppended{Proc: a, Index: 1},
+				StateChanged{Proc: a, State: StateSuccess},
+			},
+		},
+		{
+			name: "only once full",
+			size: 2,
+			publish: []Event{
+				LogAppended{Proc: a, Index: 0},
+				LogAppended{Proc: a, Index: 1},
+				LogAppended{Proc: a, Index: 2},
+				LogAppended{Proc: a, Index: 3},
+			},
+			want: []Event{
+				LogAppended{Proc: a, Index: 0},
+				LogAppended{Proc: a, Index: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBus()
+			s := b.Subscribe(Coalesce, tt.size)
+
+			// Publishing never waits
+			for _, e := range tt.publish {
+				if !returned(published(b, e)) {
+					t.Fatalf("Publish(%v) is waiting", e)
+				}
+			}
+
+			s.Close()
+			if got := drain(s); !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusOrder(t *testing.T) {
+	b := NewBus()
+	subs := []*Subscription{
+		b.Subscribe(Block, 10),
+		b.Subscribe(Block, 1),
+		b.Subscribe(Coalesce, 1000),
+	}
+
+	// Every subscriber reads as fast as it can
+	got := make([][]Event, len(subs))
+	var read sync.WaitGroup
+	for i, s := range subs {
+		read.Add(1)
+		go func() {
+			defer read.Done()
+			got[i] = drain(s)
+		}()
+	}
+
+	// Publish from many goroutines at once
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			id := uuid.New()
+			for i := range 100 {
+				b.Publish(LogAppended{Proc: id, Index: i})
+			}
+		}()
+	}
+	wg.Wait()
+
+	b.Close()
+	read.Wait()
+
+	for i := range got {
+		if len(got[i]) != 800 {
+			t.Fatalf("subscriber %d got %d events, want 800", i, len(got[i]))
+		}
+		if !slices.Equal(got[i], got[0]) {
+			t.Errorf("subscriber %d got events in a different order than subscriber 0", i)
+		}
+	}
+}
+
+func TestBusConfigReloaded(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(Block, 1)
+
+	b.Publish(ConfigReloaded{File: "treli.yaml"})
+	s.Close()
+
+	got := drain(s)
+	if len(got) != 1 || got[0].ProcID() != uuid.Nil {
+		t.Errorf("events = %v, want one that isn't of any proc", got)
+	}
+}
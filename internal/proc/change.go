@@ -0,0 +1,54 @@
+package proc
+
+import "context"
+
+// ChangePolicy is what happens when a file changes while the proc is running
+type ChangePolicy string
+
+const (
+	// ChangeRestart stops the current run and starts again
+	ChangeRestart ChangePolicy = "restart"
+	// ChangeQueue lets the current run finish, then runs once more, no matter how many changes came in since
+	ChangeQueue ChangePolicy = "queue"
+	// ChangeIgnore lets the current run finish, and doesn't run for the change
+	ChangeIgnore ChangePolicy = "ignore"
+)
+
+// Change runs the process for a file change, following OnChangeWhileRunning if it's already running.
+// Doesn't block, the process runs in the background
+func (a *Proc) Change(ctx context.Context) {
+	for {
+		nctx, err := a.begin(ctx)
+		if err == nil {
+			go a.loop(nctx, TriggerChange)
+			return
+		}
+
+		// It's running, or was started by someone else since
+		switch a.OnChangeWhileRunning {
+		case ChangeIgnore:
+			return
+
+		case ChangeQueue:
+			if a.queue() {
+				return
+			}
+
+		default:
+			a.Stop()
+		}
+	}
+}
+
+// queue makes the process run again after the current run, returns false if it isn't running anymore
+func (a *Proc) queue() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cancel == nil {
+		return false
+	}
+
+	a.pending = true
+	return true
+}
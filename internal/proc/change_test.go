@@ -0,0 +1,167 @@
+package proc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// gate is an onstart that runs until the file gate is created in the proc's dir
+const gate = "while [ ! -f gate ]; do sleep 0.01; done"
+
+// changing returns a proc that runs onstart, and echo on changes
+func changing(t *testing.T, policy ChangePolicy, onstart string) (p *Proc, dir string) {
+	t.Helper()
+
+	dir = t.TempDir()
+	p = New("changing", Options{
+		Kind:                 KindTask,
+		OnChangeWhileRunning: policy,
+		OnStart:              onstart,
+		OnChange:             "echo changed",
+		Dir:                  dir,
+	}, NewBus())
+
+	return p, dir
+}
+
+// started starts p in the background and waits until it's running, the returned channel is closed once Start returns
+func started(t *testing.T, p *Proc) chan struct{} {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Start(context.Background(), TriggerManual)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for p.State() != StateRunning {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %s, want %s", p.State(), StateRunning)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return done
+}
+
+// finished waits for done to be closed
+func finished(t *testing.T, done chan struct{}) {
+	t.Helper()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("proc is still running")
+	}
+}
+
+// variants returns what each run of p ran
+func variants(p *Proc) []Variant {
+	v := []Variant{}
+	for _, r := range p.History() {
+		v = append(v, r.Variant)
+	}
+
+	return v
+}
+
+func TestChangeWhileRunning(t *testing.T) {
+	tests := []struct {
+		policy  ChangePolicy
+		changes int
+		want    []Variant
+	}{
+		{policy: ChangeQueue, changes: 1, want: []Variant{VariantOnStart, VariantOnChange}},
+		{policy: ChangeQueue, changes: 5, want: []Variant{VariantOnStart, VariantOnChange}},
+		{policy: ChangeIgnore, changes: 1, want: []Variant{VariantOnStart}},
+		{policy: ChangeIgnore, changes: 5, want: []Variant{VariantOnStart}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d", tt.policy, tt.changes), func(t *testing.T) {
+			p, dir := changing(t, tt.policy, gate)
+			done := started(t, p)
+
+			// Changes come in at once, from the watcher's goroutines
+			changed := make(chan struct{})
+			for range tt.changes {
+				go func() {
+					p.Change(context.Background())
+					changed <- struct{}{}
+				}()
+			}
+			for range tt.changes {
+				<-changed
+			}
+
+			// The current run isn't interrupted
+			if got := p.History(); len(got) != 0 {
+				t.Fatalf("runs = %v before the first one finished, want none", got)
+			}
+
+			if err := os.WriteFile(filepath.Join(dir, "gate"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			finished(t, done)
+
+			if got := variants(p); !slices.Equal(got, tt.want) {
+				t.Errorf("runs = %v, want %v", got, tt.want)
+			}
+			if p.Active() {
+				t.Error("proc is still active")
+			}
+		})
+	}
+}
+
+func TestChangeRestart(t *testing.T) {
+	// The shell waits for sleep when it can't exec it, stopping has to reach both
+	for _, onstart := range []string{"exec sleep 60", "sleep 60 && echo done"} {
+		t.Run(onstart, func(t *testing.T) {
+			p, _ := changing(t, ChangeRestart, onstart)
+			done := started(t, p)
+
+			// Restarting stops the current run, then runs onchange in the background
+			changed := make(chan struct{})
+			go func() {
+				defer close(changed)
+				p.Change(context.Background())
+			}()
+			finished(t, changed)
+			finished(t, done)
+			p.Wait()
+
+			got := p.History()
+			if len(got) != 2 {
+				t.Fatalf("runs = %v, want 2", got)
+			}
+			if got[0].Variant != VariantOnStart || got[0].Signal == "" {
+				t.Errorf("first run = %+v, want onstart stopped by a signal", got[0])
+			}
+			if got[1].Variant != VariantOnChange || got[1].Trigger != TriggerChange || got[1].Failed() {
+				t.Errorf("second run = %+v, want onchange triggered by the change", got[1])
+			}
+		})
+	}
+}
+
+func TestChangeNotRunning(t *testing.T) {
+	for _, policy := range []ChangePolicy{ChangeRestart, ChangeQueue, ChangeIgnore} {
+		t.Run(string(policy), func(t *testing.T) {
+			p, _ := changing(t, policy, gate)
+
+			p.Change(context.Background())
+			p.Wait()
+
+			if got := variants(p); !slices.Equal(got, []Variant{VariantOnChange}) {
+				t.Errorf("runs = %v, want only onchange", got)
+			}
+		})
+	}
+}
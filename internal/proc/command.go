@@ -0,0 +1,408 @@
+package proc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// ShellNone runs commands directly, without a shell
+const ShellNone = "none"
+
+var assignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// builtins are shell builtins and keywords that are never looked up on PATH
+var builtins = []string{
+	"!", ".", ":", "[", "[[", "{", "}", "alias", "bg", "break", "builtin", "case",
+	"cd", "command", "continue", "do", "done", "echo", "elif", "else", "esac",
+	"eval", "exec", "exit", "export", "false", "fg", "fi", "for", "function",
+	"getopts", "hash", "if", "jobs", "kill", "local", "printf", "pwd", "read",
+	"readonly", "return", "select", "set", "shift", "source", "test", "then",
+	"time", "trap", "true", "type", "ulimit", "umask", "unalias", "unset",
+	"until", "wait", "while",
+}
+
+// prefixes are keywords and builtins that run the words after them as a command
+var prefixes = []string{"!", "{", "command", "do", "elif", "else", "exec", "if", "then", "time", "until", "while"}
+
+// hereDoc is a here-document whose body hasn't been read yet
+type hereDoc struct {
+	delimiter string
+
+	// <<- strips leading tabs from the body and the delimiter line
+	tabs bool
+}
+
+// simpleCommand is a command in a shell command line
+type simpleCommand struct {
+	words []string
+
+	// The subshells the command is in, outermost first, each one identified by the order it was opened in
+	subshells []int
+}
+
+// splitCommand splits a shell command into simple commands.
+// Quotes and escapes are removed, control operators separate commands and redirections are dropped,
+// so are the bodies of here-documents
+func splitCommand(command string) (cmds []simpleCommand, redirects bool, err error) {
+	words := []string{}
+	subshells := []int{}
+	opened := 0
+
+	var word strings.Builder
+	inWord := false
+	redirect := false
+
+	// Here-documents start on the line after their delimiter
+	heredocs := []hereDoc{}
+	var heredoc *hereDoc
+
+	// Ends the current word
+	endWord := func() {
+		if !inWord {
+			return
+		}
+
+		if redirect {
+			if heredoc != nil {
+				heredoc.delimiter = word.String()
+				heredocs = append(heredocs, *heredoc)
+				heredoc = nil
+			}
+			redirect = false
+		} else {
+			words = append(words, word.String())
+		}
+
+		word.Reset()
+		inWord = false
+	}
+
+	// Ends the current simple command
+	endCmd := func() {
+		endWord()
+		if len(words) > 0 {
+			cmds = append(cmds, simpleCommand{words: words, subshells: slices.Clone(subshells)})
+		}
+		words = []string{}
+	}
+
+	runes := []rune(command)
+	i := 0
+
+	// Skips the bodies of the here-documents started on the line ending at i
+	endLine := func() {
+		for _, h := range heredocs {
+			i = skipHereDoc(runes, i, h)
+		}
+		heredocs = heredocs[:0]
+	}
+
+	for ; i < len(runes); i++ {
+		r := runes[i]
+
+		switch r {
+		case '\\':
+			i++
+			if i < len(runes) && runes[i] != '\n' {
+				word.WriteRune(runes[i])
+			}
+			inWord = true
+
+		case '\'':
+			end := slices.Index(runes[i+1:], '\'')
+			if end == -1 {
+				return nil, false, errors.New("unterminated single quote")
+			}
+			word.WriteString(string(runes[i+1 : i+1+end]))
+			i += end + 1
+			inWord = true
+
+		case '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("$`\"\\", runes[i+1]) {
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, false, errors.New("unterminated double quote")
+			}
+			inWord = true
+
+		case ' ', '\t':
+			endWord()
+
+		case '\n', ';', '&', '|', '(', ')':
+			// A & directly after a redirection is a file descriptor, e.g. 2>&1
+			if r == '&' && i > 0 && (runes[i-1] == '>' || runes[i-1] == '<') {
+				continue
+			}
+			endCmd()
+
+			switch {
+			case r == '(':
+				opened++
+				subshells = append(subshells, opened)
+			case r == ')' && len(subshells) > 0:
+				subshells = subshells[:len(subshells)-1]
+			case r == '\n':
+				endLine()
+			}
+
+		case '<', '>':
+			// A leading number is the file descriptor being redirected
+			if inWord && strings.Trim(word.String(), "0123456789") == "" {
+				word.Reset()
+				inWord = false
+			}
+			endWord()
+			redirect = true
+			redirects = true
+
+			// << is a here-document, <<< a here-string that's just a word
+			if r == '<' && i+1 < len(runes) && runes[i+1] == '<' && (i == 0 || runes[i-1] != '<') && (i+2 >= len(runes) || runes[i+2] != '<') {
+				i++
+				heredoc = &hereDoc{}
+				if i+1 < len(runes) && runes[i+1] == '-' {
+					i++
+					heredoc.tabs = true
+				}
+			}
+
+		case '#':
+			if inWord {
+				word.WriteRune(r)
+				continue
+			}
+
+			// Comment, skip to end of line
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			endCmd()
+			endLine()
+
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endCmd()
+
+	return cmds, redirects, nil
+}
+
+// skipHereDoc skips the body of h, starting after the newline at i.
+// Returns the index of the newline ending the delimiter line, or the end of runes
+func skipHereDoc(runes []rune, i int, h hereDoc) int {
+	for i < len(runes) {
+		end := i + 1
+		for end < len(runes) && runes[end] != '\n' {
+			end++
+		}
+
+		line := string(runes[i+1 : end])
+		if h.tabs {
+			line = strings.TrimLeft(line, "\t")
+		}
+		i = end
+		if line == h.delimiter {
+			break
+		}
+	}
+
+	return i
+}
+
+// splitArgs splits a command run without a shell into environment variables and argv
+func splitArgs(command string) (env []string, argv []string, err error) {
+	cmds, redirects, err := splitCommand(command)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(cmds) != 1 {
+		return nil, nil, errors.New("only a single command can run without a shell")
+	}
+	if redirects {
+		return nil, nil, errors.New("redirections need a shell")
+	}
+
+	argv = cmds[0].words
+	for len(argv) > 0 && assignment.MatchString(argv[0]) {
+		env = append(env, argv[0])
+		argv = argv[1:]
+	}
+	if len(argv) == 0 {
+		return nil, nil, errors.New("no program to run")
+	}
+
+	return env, argv, nil
+}
+
+// newCmd creates the exec.Cmd that runs command, either through shell or directly.
+// env is added to the environment of the current process
+func newCmd(command string, dir string, shell string, env []string) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+
+	if shell == ShellNone {
+		assignments, argv, err := splitArgs(command)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd = exec.Command(argv[0], argv[1:]...)
+		env = append(slices.Clone(env), assignments...)
+	} else {
+		cmd = exec.Command(shell, "-c", command)
+	}
+
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	return cmd, nil
+}
+
+// checkCommand makes sure every program a command runs can be found,
+// relative executables are resolved against dir.
+// Relative executables after another program aren't checked, it may be what builds them
+func checkCommand(command string, dir string, shell string) []error {
+	if shell == ShellNone {
+		_, argv, err := splitArgs(command)
+		if err != nil {
+			return []error{err}
+		}
+		if slices.Contains(builtins, argv[0]) {
+			return []error{fmt.Errorf("%s is a shell builtin", argv[0])}
+		}
+		if err := findExecutable(argv[0], dir); err != nil {
+			return []error{err}
+		}
+
+		return nil
+	}
+
+	errs := []error{}
+	if _, err := exec.LookPath(shell); err != nil {
+		errs = append(errs, err)
+	}
+
+	cmds, _, err := splitCommand(command)
+	if err != nil {
+		return append(errs, err)
+	}
+
+	// Directory changes only last until the end of the subshell they're in, 0 is outside of any
+	dirs := map[int]string{0: dir}
+	ran := false
+	for _, cmd := range cmds {
+		scope := 0
+		if len(cmd.subshells) > 0 {
+			scope = cmd.subshells[len(cmd.subshells)-1]
+		}
+		cwd := dir
+		for _, s := range append([]int{0}, cmd.subshells...) {
+			if d, ok := dirs[s]; ok {
+				cwd = d
+			}
+		}
+
+		words := program(cmd.words)
+		if len(words) == 0 {
+			continue
+		}
+
+		name := words[0]
+
+		// Can't know what an expansion will run
+		if strings.ContainsAny(name, "$`*?") {
+			continue
+		}
+
+		if slices.Contains(builtins, name) {
+			// Follow directory changes so later relative executables resolve correctly
+			if name == "cd" && len(words) > 1 && !strings.ContainsAny(words[1], "$`~") {
+				dirs[scope] = resolveDir(cwd, words[1])
+			}
+
+			continue
+		}
+
+		if ran && strings.ContainsRune(name, '/') && !filepath.IsAbs(name) {
+			continue
+		}
+		ran = true
+
+		if err := findExecutable(name, cwd); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// program skips the environment variables, keywords and prefixes like exec before the program a command runs,
+// returning the program and its arguments
+func program(words []string) []string {
+	for len(words) > 0 {
+		switch {
+		case assignment.MatchString(words[0]):
+			words = words[1:]
+
+		case slices.Contains(prefixes, words[0]):
+			words = words[1:]
+
+			// Options of the prefix, like time -p or exec -a name
+			for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+				if words[0] == "-a" && len(words) > 1 {
+					words = words[1:]
+				}
+				words = words[1:]
+			}
+
+		default:
+			return words
+		}
+	}
+
+	return nil
+}
+
+// findExecutable looks for name on PATH, or relative to dir if it contains a path separator
+func findExecutable(name string, dir string) error {
+	if !strings.ContainsRune(name, '/') && !strings.ContainsRune(name, filepath.Separator) {
+		_, err := exec.LookPath(name)
+		return err
+	}
+
+	path := resolveDir(dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s: is a directory", name)
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return fmt.Errorf("%s: permission denied", name)
+	}
+
+	return nil
+}
+
+func resolveDir(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,242 @@
+package proc
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		want      [][]string
+		subshells [][]int
+		redirects bool
+		wantErr   bool
+	}{
+		{
+			name:    "words",
+			command: "go run  .\t-v",
+			want:    [][]string{{"go", "run", ".", "-v"}},
+		},
+		{
+			name:    "single quotes",
+			command: `echo 'a "b" \c'`,
+			want:    [][]string{{"echo", `a "b" \c`}},
+		},
+		{
+			name:    "double quotes",
+			command: `echo "a 'b' \"c\" \$d \e"`,
+			want:    [][]string{{"echo", `a 'b' "c" $d \e`}},
+		},
+		{
+			name:    "escapes",
+			command: `echo a\ b \;`,
+			want:    [][]string{{"echo", "a b", ";"}},
+		},
+		{
+			name:    "adjacent quotes",
+			command: `echo a'b'"c"`,
+			want:    [][]string{{"echo", "abc"}},
+		},
+		{
+			name:    "control operators",
+			command: "a && b || c; d & e\nf",
+			want:    [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}, {"f"}},
+		},
+		{
+			name:    "pipes",
+			command: "cat file | grep x |& sort",
+			want:    [][]string{{"cat", "file"}, {"grep", "x"}, {"sort"}},
+		},
+		{
+			name:      "redirects",
+			command:   "make >out.log 2>&1 < in 2> err",
+			want:      [][]string{{"make"}},
+			redirects: true,
+		},
+		{
+			name:    "comments",
+			command: "a # b\nc d#e",
+			want:    [][]string{{"a"}, {"c", "d#e"}},
+		},
+		{
+			name:      "subshells",
+			command:   "a; (b; (c) && d); e; (f)",
+			want:      [][]string{{"a"}, {"b"}, {"c"}, {"d"}, {"e"}, {"f"}},
+			subshells: [][]int{{}, {1}, {1, 2}, {1}, {}, {3}},
+		},
+		{
+			name:      "heredoc",
+			command:   "cat <<EOF > out\nhello world\nEOF\nmake",
+			want:      [][]string{{"cat"}, {"make"}},
+			redirects: true,
+		},
+		{
+			name:      "quoted heredoc",
+			command:   "cat <<'END' && a\n$b\n  END\nEND\nc",
+			want:      [][]string{{"cat"}, {"a"}, {"c"}},
+			redirects: true,
+		},
+		{
+			name:      "heredoc tabs",
+			command:   "cat <<-EOF # body\n\tb\n\tEOF\nc",
+			want:      [][]string{{"cat"}, {"c"}},
+			redirects: true,
+		},
+		{
+			name:      "heredocs",
+			command:   "a <<A; b <<B\nx\nA\ny\nB\nc",
+			want:      [][]string{{"a"}, {"b"}, {"c"}},
+			redirects: true,
+		},
+		{
+			name:      "unterminated heredoc",
+			command:   "cat <<EOF\nx",
+			want:      [][]string{{"cat"}},
+			redirects: true,
+		},
+		{
+			name:      "here-string",
+			command:   "cat <<< word\nb",
+			want:      [][]string{{"cat"}, {"b"}},
+			redirects: true,
+		},
+		{
+			name:    "unterminated single quote",
+			command: "echo 'a",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated double quote",
+			command: `echo "a`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmds, redirects, err := splitCommand(tt.command)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("splitCommand() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := [][]string{}
+			subshells := [][]int{}
+			for _, cmd := range cmds {
+				got = append(got, cmd.words)
+				subshells = append(subshells, cmd.subshells)
+			}
+
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("words = %q, want %q", got, tt.want)
+			}
+			if tt.subshells != nil && !slices.EqualFunc(subshells, tt.subshells, slices.Equal) {
+				t.Errorf("subshells = %v, want %v", subshells, tt.subshells)
+			}
+			if redirects != tt.redirects {
+				t.Errorf("redirects = %v, want %v", redirects, tt.redirects)
+			}
+		})
+	}
+}
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{words: []string{"go", "run", "."}, want: []string{"go", "run", "."}},
+		{words: []string{"FOO=1", "BAR=", "go", "run"}, want: []string{"go", "run"}},
+		{words: []string{"exec", "./app", "-v"}, want: []string{"./app", "-v"}},
+		{words: []string{"exec", "-a", "name", "./app"}, want: []string{"./app"}},
+		{words: []string{"then", "FOO=1", "make"}, want: []string{"make"}},
+		{words: []string{"if", "!", "./check"}, want: []string{"./check"}},
+		{words: []string{"time", "-p", "make"}, want: []string{"make"}},
+		{words: []string{"command", "-v", "go"}, want: []string{"go"}},
+		{words: []string{"do"}, want: nil},
+		{words: []string{"cd", "client"}, want: []string{"cd", "client"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.words, " "), func(t *testing.T) {
+			if got := program(tt.words); !slices.Equal(got, tt.want) {
+				t.Errorf("program() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"app", "client/build", "client/src/gen"} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		shell   string
+		errs    int
+	}{
+		{name: "on path", command: "sh -c true", errs: 0},
+		{name: "missing", command: "treli-missing-program", errs: 1},
+		{name: "env prefix", command: "FOO=1 sh", errs: 0},
+		{name: "builtin", command: "echo hi && export A=1", errs: 0},
+		{name: "relative", command: "./app", errs: 0},
+		{name: "relative missing", command: "./nope", errs: 1},
+		{name: "not executable", command: "./data", errs: 1},
+		{name: "directory", command: "./client", errs: 1},
+		{name: "cd", command: "cd client && ./build", errs: 0},
+		{name: "nested cd", command: "cd client; cd src; ./gen", errs: 0},
+		{name: "cd in subshell", command: "(cd client && ./build) && ./app", errs: 0},
+		{name: "cd in subshell doesn't last", command: "(cd client) && ./build", errs: 1},
+		{name: "cd in sibling subshell doesn't last", command: "(cd client && echo); (./build)", errs: 1},
+		{name: "cd before subshell", command: "cd client && (./build; cd src && ./gen)", errs: 0},
+		{name: "pipe", command: "./app | treli-missing-program", errs: 1},
+		{name: "redirect", command: "./app > ./nope 2>&1", errs: 0},
+		{name: "exec", command: "exec ./nope", errs: 1},
+		{name: "keyword", command: "if ./nope; then ./app; fi", errs: 1},
+		{name: "built first", command: "go build -o ./tmp/app . && ./tmp/app", errs: 0},
+		{name: "built after cd", command: "cd client && sh gen.sh; ./nope", errs: 0},
+		{name: "on path after a program", command: "./app && treli-missing-program", errs: 1},
+		{name: "heredoc", command: "cat <<EOF\nhello world\nEOF", errs: 0},
+		{name: "keywords", command: "while ./app; do ./app; done", errs: 0},
+		{name: "expansion", command: "$CMD && ./*", errs: 0},
+		{name: "no shell", command: "FOO=1 ./app -v", shell: ShellNone, errs: 0},
+		{name: "no shell missing", command: "./nope", shell: ShellNone, errs: 1},
+		{name: "no shell builtin", command: "exec ./app", shell: ShellNone, errs: 1},
+		{name: "no shell pipe", command: "./app | ./app", shell: ShellNone, errs: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell := tt.shell
+			if shell == "" {
+				shell = "sh"
+			}
+
+			if errs := checkCommand(tt.command, dir, shell); len(errs) != tt.errs {
+				t.Errorf("checkCommand() = %v, want %d errors", errs, tt.errs)
+			}
+		})
+	}
+}
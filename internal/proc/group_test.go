@@ -0,0 +1,107 @@
+//go:build !windows
+
+package proc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// compound returns a proc running a command the shell can't exec, so it waits for the sleep it starts.
+// The pid of the sleep is written to the file pid in the proc's dir
+func compound(t *testing.T, usePty bool) (p *Proc, dir string) {
+	t.Helper()
+
+	dir = t.TempDir()
+	p = New("compound", Options{
+		OnStart: "sh -c 'echo $$ > pid; exec sleep 60' && echo done",
+		Dir:     dir,
+		Pty:     usePty,
+	}, NewBus())
+
+	return p, dir
+}
+
+// sleeping waits for the sleep of a compound proc to start, returning its pid
+func sleeping(t *testing.T, dir string) int {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b, _ := os.ReadFile(filepath.Join(dir, "pid"))
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil {
+			return pid
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("sleep didn't start")
+	return 0
+}
+
+// exited fails the test if the process pid doesn't exit within a few seconds, a zombie has exited
+func exited(t *testing.T, pid int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out, _ := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+		stat := strings.TrimSpace(string(out))
+		if stat == "" || strings.HasPrefix(stat, "Z") {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("process %d is still running", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopGroup(t *testing.T) {
+	for _, usePty := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pty %v", usePty), func(t *testing.T) {
+			p, dir := compound(t, usePty)
+			done := started(t, p)
+			pid := sleeping(t, dir)
+
+			// Stopping interrupts what the shell started too, instead of waiting for it
+			stopped := make(chan struct{})
+			go func() {
+				defer close(stopped)
+				p.Stop()
+			}()
+			finished(t, stopped)
+			finished(t, done)
+			exited(t, pid)
+
+			if p.Active() {
+				t.Error("proc is still active")
+			}
+		})
+	}
+}
+
+func TestKillGroup(t *testing.T) {
+	for _, usePty := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pty %v", usePty), func(t *testing.T) {
+			p, dir := compound(t, usePty)
+			done := started(t, p)
+			pid := sleeping(t, dir)
+
+			if err := p.Kill(); err != nil {
+				t.Fatal(err)
+			}
+			finished(t, done)
+
+			// Nothing is left running in the background
+			exited(t, pid)
+		})
+	}
+}
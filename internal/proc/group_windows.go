@@ -0,0 +1,19 @@
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setGroup does nothing, there are no process groups to signal on windows
+func setGroup(cmd *exec.Cmd) {}
+
+// signalGroup sends sig to process only, interrupts aren't supported
+func signalGroup(process *os.Process, sig syscall.Signal) error {
+	if sig == syscall.SIGKILL {
+		return process.Kill()
+	}
+
+	return process.Signal(sig)
+}
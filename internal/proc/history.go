@@ -0,0 +1,103 @@
+package proc
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// historySize is the number of runs kept per proc
+const historySize = 50
+
+type Trigger int
+
+const (
+	TriggerAutostart Trigger = iota
+	TriggerChange
+	TriggerManual
+	TriggerDependency
+	TriggerRestart
+	TriggerManualRestart
+)
+
+var triggerName = map[Trigger]string{
+	TriggerAutostart:  "autostart",
+	TriggerChange:     "file change",
+	TriggerManual:     "manual",
+	TriggerDependency: "dependency",
+	TriggerRestart:    "restart policy",
+
+	TriggerManualRestart: "manual restart",
+}
+
+func (t Trigger) String() string {
+	return triggerName[t]
+}
+
+type Variant string
+
+const (
+	VariantOnStart  Variant = "onstart"
+	VariantOnChange Variant = "onchange"
+)
+
+// Run is a record of a single run of a proc
+type Run struct {
+	ID       int
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+	ExitCode int
+	Signal   string
+	Trigger  Trigger
+	Variant  Variant
+}
+
+// Failed returns whether the run did not exit successfully
+func (r Run) Failed() bool {
+	return r.ExitCode != 0 || r.Signal != ""
+}
+
+// finish records the end of the run, and how it exited
+func (r *Run) finish(err error) {
+	r.End = time.Now()
+	r.Duration = r.End.Sub(r.Start)
+
+	var exitError *exec.ExitError
+	switch {
+	case err == nil:
+		r.ExitCode = 0
+
+	case errors.As(err, &exitError):
+		r.ExitCode = exitError.ExitCode()
+		if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			r.Signal = status.Signal().String()
+		}
+
+	default:
+		r.ExitCode = -1
+	}
+}
+
+func (a *Proc) addRun(run Run) {
+	a.mu.Lock()
+	a.history = append(a.history, run)
+	if len(a.history) > historySize {
+		a.history = a.history[len(a.history)-historySize:]
+	}
+	a.mu.Unlock()
+
+	a.publish(RunFinished{Proc: a.ID, Run: run})
+}
+
+// History returns the most recent runs of the proc, oldest first
+func (a *Proc) History() []Run {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	history := make([]Run, len(a.history))
+	copy(history, a.history)
+
+	return history
+}
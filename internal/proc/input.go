@@ -0,0 +1,43 @@
+package proc
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNoInput is returned when writing to a proc that doesn't take input
+var ErrNoInput = errors.New("input is not enabled, set stdin or pty")
+
+// Write sends input to the stdin of the running process, or its pseudo-terminal
+func (a *Proc) Write(b []byte) (int, error) {
+	if !a.TakesInput() {
+		return 0, ErrNoInput
+	}
+
+	a.mu.Lock()
+	stdin := a.stdin
+	a.mu.Unlock()
+
+	if stdin == nil {
+		return 0, errors.New("process is not running")
+	}
+
+	return stdin.Write(b)
+}
+
+// TakesInput returns whether input can be written to the process, through its stdin or pseudo-terminal
+func (a *Proc) TakesInput() bool {
+	return a.pty || a.input
+}
+
+// UsesPty returns whether the process runs in a pseudo-terminal, which echoes its input
+func (a *Proc) UsesPty() bool {
+	return a.pty
+}
+
+func (a *Proc) setStdin(stdin io.WriteCloser) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stdin = stdin
+}
@@ -0,0 +1,10 @@
+package proc
+
+type Kind string
+
+const (
+	// KindTask runs once and exits, like a linter or a build
+	KindTask Kind = "task"
+	// KindService keeps running until it's stopped, like a server
+	KindService Kind = "service"
+)
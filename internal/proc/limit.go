@@ -0,0 +1,53 @@
+package proc
+
+import "context"
+
+// Semaphore limits how many procs can run at once
+type Semaphore struct {
+	slots chan struct{}
+}
+
+func NewSemaphore(n int) *Semaphore {
+	return &Semaphore{
+		slots: make(chan struct{}, max(n, 1)),
+	}
+}
+
+func (s *Semaphore) acquire(ctx context.Context) error {
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Semaphore) release() {
+	<-s.slots
+}
+
+// acquire waits for a slot in every limit of the proc, queued until there is.
+// Limits are always acquired in the same order, so procs can't wait on each other.
+// Returns a function that releases them
+func (a *Proc) acquire(ctx context.Context) (func(), error) {
+	acquired := []*Semaphore{}
+	release := func() {
+		for _, s := range acquired {
+			s.release()
+		}
+	}
+
+	for _, s := range a.limits {
+		if len(s.slots) == cap(s.slots) {
+			a.setState(StateQueued)
+		}
+
+		if err := s.acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+		acquired = append(acquired, s)
+	}
+
+	return release, nil
+}
@@ -0,0 +1,26 @@
+package proc
+
+import "time"
+
+// Stream is where a line of output came from
+type Stream string
+
+const (
+	StreamStdout Stream = "out"
+	StreamStderr Stream = "err"
+	// StreamSystem lines are from treli, like exit codes
+	StreamSystem Stream = "sys"
+)
+
+// Line is a single line of output captured from a proc
+type Line struct {
+	Time   time.Time
+	Text   string
+	Stream Stream
+
+	// Run is the ID of the run the line was captured during, 0 before the first run
+	Run int
+
+	// Partial lines are still being written, and will be overwritten
+	Partial bool
+}
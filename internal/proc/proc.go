@@ -1,186 +1,609 @@
 package proc
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/google/uuid"
+	"github.com/spotdemo4/treli/internal/util"
 )
 
-type Proc struct {
-	Name        string
-	Exts        []string
-	AutoStart   bool
-	AutoRestart bool
+var (
+	ErrStarted    = errors.New("process has already started")
+	ErrNotStarted = errors.New("process has not started")
+)
 
-	command  string
+type Proc struct {
+	ID        uuid.UUID
+	Name      string
+	Color     string
+	Exts      []string
+	AutoStart bool
+	Restart   Restart
+	Kind      Kind
+	DependsOn []string
+
+	OnChangeWhileRunning ChangePolicy
+
+	commands map[Variant]string
 	dir      string
 	shell    string
-	onchange chan int
-
-	logs   []string
-	state  State
-	wg     *sync.WaitGroup
-	mu     *sync.Mutex
-	cancel *context.CancelFunc
-}
-
-func New(
-	name string,
-	exts []string,
-	autoStart bool,
-	autoRestart bool,
-	command string,
-	dir string,
-	shell string,
-	onchange chan int,
-) (*Proc, error) {
-	// Make sure command exists in path
-	cmd := strings.Split(command, " ")
-	_, err := exec.LookPath(cmd[0])
-	if err != nil {
-		return nil, err
+	env      []string
+	pty      bool
+	input    bool
+	limits   []*Semaphore
+	bus      *Bus
+
+	logs     []Line
+	cut      map[Stream]string
+	warnings []string
+	history  []Run
+	runs     int
+	state    State
+	stopped  bool
+	pending  bool
+	done     chan struct{}
+	mu       *sync.Mutex
+	cancel   *context.CancelFunc
+	ptmx     *os.File
+	size     *pty.Winsize
+	stdin    io.WriteCloser
+	process  *os.Process
+}
+
+// Options configure a proc, unset values have defaults
+type Options struct {
+	Color     string
+	Exts      []string
+	AutoStart bool
+	Restart   Restart
+	Kind      Kind
+	DependsOn []string
+
+	OnChangeWhileRunning ChangePolicy
+
+	// Commands run when the proc starts, and when a file changes or it's restarted
+	OnStart  string
+	OnChange string
+
+	// Dir is where the commands run, through Shell with Env added to the environment
+	Dir   string
+	Shell string
+	Env   []string
+
+	// Pty runs the commands in a pseudo-terminal, Stdin lets keys be sent to them without one
+	Pty   bool
+	Stdin bool
+
+	// Limits are waited on before each run
+	Limits []*Semaphore
+}
+
+// withDefaults fills in unset values
+func (o Options) withDefaults() Options {
+	if o.Kind == "" {
+		o.Kind = KindService
 	}
+	if o.OnChangeWhileRunning == "" {
+		o.OnChangeWhileRunning = ChangeRestart
+	}
+	if o.Shell == "" {
+		o.Shell = "sh"
+	}
+	o.Restart = o.Restart.withDefaults()
+
+	return o
+}
+
+// New creates a proc, events about it are published to bus
+func New(name string, opts Options, bus *Bus) *Proc {
+	opts = opts.withDefaults()
 
 	app := Proc{
-		Name:        name,
-		Exts:        exts,
-		AutoStart:   autoStart,
-		AutoRestart: autoRestart,
+		ID:        uuid.New(),
+		Name:      name,
+		Color:     opts.Color,
+		Exts:      opts.Exts,
+		AutoStart: opts.AutoStart,
+		Restart:   opts.Restart,
+		Kind:      opts.Kind,
+		DependsOn: opts.DependsOn,
+
+		OnChangeWhileRunning: opts.OnChangeWhileRunning,
+
+		commands: map[Variant]string{
+			VariantOnStart:  opts.OnStart,
+			VariantOnChange: opts.OnChange,
+		},
+		dir:    opts.Dir,
+		shell:  opts.Shell,
+		env:    opts.Env,
+		pty:    opts.Pty,
+		input:  opts.Stdin,
+		limits: opts.Limits,
+		bus:    bus,
+
+		logs:    []Line{},
+		cut:     map[Stream]string{},
+		history: []Run{},
+		state:   StateIdle,
+		mu:      &sync.Mutex{},
+	}
 
-		command:  command,
-		dir:      dir,
-		shell:    shell,
-		onchange: onchange,
+	// Make sure every program the commands run exists
+	for _, variant := range []Variant{VariantOnStart, VariantOnChange} {
+		command := app.commands[variant]
+		if command == "" {
+			continue
+		}
 
-		logs:  []string{},
-		state: StateIdle,
-		wg:    &sync.WaitGroup{},
-		mu:    &sync.Mutex{},
+		for _, err := range checkCommand(command, app.dir, app.shell) {
+			app.warn("%s: %s", variant, err.Error())
+		}
 	}
 
-	return &app, nil
+	return &app
 }
 
 // Stops the process and waits for process to stop
 func (a *Proc) Stop() error {
-	cancel := a.getCancel()
+	a.mu.Lock()
+	a.stopped = true
+	cancel := a.cancel
+	a.mu.Unlock()
+
 	if cancel == nil {
-		return errors.New("process has not started")
+		return ErrNotStarted
 	}
 
+	a.swapState(StateStopping, StateRunning, StateStarting)
+
 	(*cancel)()
 	a.Wait()
 
 	return nil
 }
 
-// Starts the process
-func (a *Proc) Start(ctx context.Context) error {
-	if a.getCancel() != nil {
-		return errors.New("process has already started")
+// Kills the process, without giving it a chance to exit cleanly
+func (a *Proc) Kill() error {
+	a.setStopped(true)
+
+	cancel := a.getCancel()
+	if cancel == nil {
+		return ErrNotStarted
+	}
+	(*cancel)()
+
+	a.mu.Lock()
+	process := a.process
+	a.mu.Unlock()
+
+	if process != nil {
+		return signalGroup(process, syscall.SIGKILL)
+	}
+
+	return nil
+}
+
+// Starts the process, trigger is the reason it's being started.
+// Blocks until the process stops
+func (a *Proc) Start(ctx context.Context, trigger Trigger) error {
+	nctx, err := a.begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	return a.loop(nctx, trigger)
+}
+
+// begin marks the process as started, returning the context it runs in.
+// Checking and marking happen at once, so only one caller can start it
+func (a *Proc) begin(ctx context.Context) (context.Context, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cancel != nil {
+		return nil, ErrStarted
 	}
 
 	nctx, cancel := context.WithCancel(ctx)
-	a.setCancel(&cancel)
-	defer a.setCancel(nil)
+	a.cancel = &cancel
+	a.done = make(chan struct{})
+	a.stopped = false
+
+	return nctx, nil
+}
+
+// loop runs the process after begin, again while changes were queued during the last run
+func (a *Proc) loop(ctx context.Context, trigger Trigger) error {
+	for {
+		err := a.run(ctx, trigger)
+		if a.end(ctx) {
+			return err
+		}
 
-	return a.run(nctx)
+		trigger = TriggerChange
+	}
+}
+
+// end marks the process as stopped, unless changes were queued while it ran and it should run again.
+// Checking and marking happen at once, so a change is either queued in time or starts it again
+func (a *Proc) end(ctx context.Context) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.pending && ctx.Err() == nil {
+		a.pending = false
+		return false
+	}
+
+	a.pending = false
+	(*a.cancel)()
+	a.cancel = nil
+	close(a.done)
+
+	return true
+}
+
+// Restarts the process, stopping it and waiting for it to stop if it's running.
+// Blocks like Start does
+func (a *Proc) Rerun(ctx context.Context) error {
+	a.Stop()
+	a.Wait()
+
+	return a.Start(ctx, TriggerManualRestart)
+}
+
+// Active returns whether the process is running, or waiting to restart
+func (a *Proc) Active() bool {
+	return a.getCancel() != nil
 }
 
 // Waits for the process to stop
 func (a *Proc) Wait() {
-	a.wg.Wait()
+	a.mu.Lock()
+	done := a.done
+	a.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
 }
 
-// Runs the process
-func (a *Proc) run(ctx context.Context) error {
-	a.wg.Add(1)
-	defer a.wg.Done()
+// Runs the process, restarting it according to the restart policy
+func (a *Proc) run(ctx context.Context, trigger Trigger) error {
+	// File changes and restarts run onchange if there is one
+	variant := VariantOnStart
+	if (trigger == TriggerChange || trigger == TriggerManualRestart) && a.commands[VariantOnChange] != "" {
+		variant = VariantOnChange
+	}
 
-	// Create exec.Cmd
-	cmd := exec.Command(a.shell, "-c", a.command)
-	if a.dir != "" {
-		cmd.Dir = a.dir
+	b := newBackoff(a.Restart)
+	for {
+		// Wait for a slot when too much is running
+		release, err := a.acquire(ctx)
+		if err != nil {
+			a.swapState(StateIdle, StateQueued)
+			return err
+		}
+
+		started := time.Now()
+		err = a.exec(ctx, trigger, variant)
+		release()
+		trigger = TriggerRestart
+
+		// Check if we should restart
+		if ctx.Err() != nil || !a.Restart.shouldRestart(err) {
+			return err
+		}
+
+		delay, ok := b.next(time.Since(started))
+		if !ok {
+			a.setState(StateCrashLoop)
+			a.log("restarted %d times within %s, giving up", a.Restart.MaxRestarts, a.Restart.Window)
+			return err
+		}
+
+		a.log("restarting in %s", delay)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
 	}
+}
 
-	// Create output pipe
-	rpipe, wpipe, err := os.Pipe()
-	if err != nil {
-		return err
+// Runs the process once, recording the run in the history
+func (a *Proc) exec(ctx context.Context, trigger Trigger, variant Variant) (err error) {
+	run := Run{
+		ID:      a.nextRun(),
+		Start:   time.Now(),
+		Trigger: trigger,
+		Variant: variant,
 	}
-	cmd.Stdout = wpipe
-	cmd.Stderr = wpipe
+	a.publish(Triggered{Proc: a.ID, Run: run.ID, Trigger: trigger, Variant: variant})
 
-	// Read from pipe
-	scanner := bufio.NewScanner(rpipe)
-	go func() {
-		for scanner.Scan() {
-			a.log("%s", scanner.Text())
-		}
+	defer func() {
+		run.finish(err)
+		a.addRun(run)
 	}()
 
+	a.setState(StateStarting)
+
+	// Create exec.Cmd
+	cmd, err := newCmd(a.commands[variant], a.dir, a.shell, a.env)
+	if err != nil {
+		a.setState(StateError)
+		a.log("%s", err.Error())
+		return err
+	}
+
 	// Start cmd
-	err = cmd.Start()
+	outs, err := a.start(cmd)
 	if err != nil {
+		a.setState(StateError)
+		a.log("%s", err.Error())
 		return err
 	}
-	a.setState(StateRunning)
+	closeOutputs := func() {
+		for _, out := range outs {
+			out.Close()
+		}
+	}
+	defer closeOutputs()
+	a.setProcess(cmd.Process)
+	defer a.setProcess(nil)
+
+	// Stopping may have been asked for while starting
+	a.swapState(StateRunning, StateStarting)
+
+	// Read output
+	var wg sync.WaitGroup
+	for stream, out := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			readLines(out, func(line []byte, partial bool) {
+				a.output(stream, util.SanitizeANSI(string(line)), partial)
+			})
+		}()
+	}
+	read := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(read)
+	}()
 
 	// Watch for stop
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		<-ctx.Done()
-		err := cmd.Process.Signal(os.Interrupt)
-		if err != nil {
-			cmd.Process.Kill()
+		select {
+		case <-ctx.Done():
+			err := signalGroup(cmd.Process, syscall.SIGINT)
+			if err != nil {
+				signalGroup(cmd.Process, syscall.SIGKILL)
+			}
+		case <-done:
 		}
 	}()
 
-	// Wait for command to complete
+	// Wait for command to complete, and the output to be read.
+	// Children left running in the background can keep the output open, so don't wait forever
 	err = cmd.Wait()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		closeOutputs()
+		<-read
+	}
+	a.setPty(nil)
+	a.setStdin(nil)
 	if err != nil {
 		a.setState(StateError)
 
 		if exitError, ok := err.(*exec.ExitError); ok {
-			a.log("exited with code %d", exitError.ExitCode())
+			if status, ok := exitError.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				a.log("killed by signal %s", status.Signal())
+			} else {
+				a.log("exited with code %d", exitError.ExitCode())
+			}
 		}
 	} else {
 		a.setState(StateSuccess)
 	}
 
-	// Check if we should restart
-	if a.AutoRestart && ctx.Err() == nil {
-		a.run(ctx)
+	return err
+}
+
+// start starts cmd, in a pseudo-terminal if enabled, returning where each stream of its output can be read from.
+// A pseudo-terminal has both streams in one.
+// cmd runs in a process group of its own, so stopping it stops what it started too
+func (a *Proc) start(cmd *exec.Cmd) (map[Stream]io.ReadCloser, error) {
+	// A pseudo-terminal starts a session, which is a process group already
+	if a.pty {
+		_, size := a.getPty()
+		ptmx, err := pty.StartWithSize(cmd, size)
+		if err != nil {
+			return nil, err
+		}
+		a.setPty(ptmx)
+		a.setStdin(ptmx)
+
+		return map[Stream]io.ReadCloser{StreamStdout: ptmx}, nil
 	}
 
-	return nil
+	outs := map[Stream]io.ReadCloser{}
+	closeOutputs := func() {
+		for _, out := range outs {
+			out.Close()
+		}
+	}
+
+	for _, stream := range []Stream{StreamStdout, StreamStderr} {
+		rpipe, wpipe, err := os.Pipe()
+		if err != nil {
+			closeOutputs()
+			return nil, err
+		}
+		defer wpipe.Close()
+		outs[stream] = rpipe
+
+		if stream == StreamStdout {
+			cmd.Stdout = wpipe
+		} else {
+			cmd.Stderr = wpipe
+		}
+	}
+
+	// Stdin is /dev/null unless input is enabled, so commands reading it don't wait forever
+	var stdin io.WriteCloser
+	if a.input {
+		var err error
+		stdin, err = cmd.StdinPipe()
+		if err != nil {
+			closeOutputs()
+			return nil, err
+		}
+	}
+
+	setGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		closeOutputs()
+		return nil, err
+	}
+	if stdin != nil {
+		a.setStdin(stdin)
+	}
+
+	return outs, nil
+}
+
+// output adds a line of output from the process, overwriting the last line if it's partial and from the same stream
+func (a *Proc) output(stream Stream, text string, partial bool) {
+	a.append(Line{
+		Time:    time.Now(),
+		Text:    text,
+		Stream:  stream,
+		Partial: partial,
+	})
 }
 
 func (a *Proc) log(msg string, ext ...any) {
+	a.append(Line{
+		Time:   time.Now(),
+		Text:   fmt.Sprintf(msg, ext...),
+		Stream: StreamSystem,
+	})
+}
+
+func (a *Proc) append(line Line) {
+	events := []Event{}
+
+	a.mu.Lock()
+	line.Run = a.runs
+
+	// Only add what's new to a line that was cut off by another stream
+	if cut, ok := a.cut[line.Stream]; ok {
+		line.Text = strings.TrimPrefix(line.Text, cut)
+		if !line.Partial {
+			delete(a.cut, line.Stream)
+		}
+	}
+
+	n := len(a.logs)
+	switch {
+	case n > 0 && a.logs[n-1].Partial && a.logs[n-1].Stream == line.Stream:
+		line.Time = a.logs[n-1].Time
+		a.logs[n-1] = line
+
+	case n > 0 && a.logs[n-1].Partial:
+		// A partial line won't be continued after this, its stream continues on a new line
+		a.logs[n-1].Partial = false
+		a.cut[a.logs[n-1].Stream] += a.logs[n-1].Text
+		events = append(events, LogAppended{Proc: a.ID, Index: n - 1, Line: a.logs[n-1]})
+		fallthrough
+
+	default:
+		a.logs = append(a.logs, line)
+	}
+	events = append(events, LogAppended{Proc: a.ID, Index: len(a.logs) - 1, Line: line})
+	a.mu.Unlock()
+
+	for _, e := range events {
+		a.publish(e)
+	}
+}
+
+func (a *Proc) warn(msg string, ext ...any) {
+	warning := fmt.Sprintf(msg, ext...)
+
+	a.mu.Lock()
+	a.warnings = append(a.warnings, warning)
+	a.mu.Unlock()
+
+	a.log("warning: %s", warning)
+}
+
+// Warnings returns problems found with the proc's configuration
+func (a *Proc) Warnings() []string {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	a.logs = append(a.logs, fmt.Sprintf(msg, ext...))
-	a.update()
+	return slices.Clone(a.warnings)
 }
 
-func (a *Proc) Logs() []string {
+// Logs returns every line of output, a partial last line is a snapshot of it
+func (a *Proc) Logs() []Line {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	return a.logs
+	return slices.Clone(a.logs)
 }
 
-func (a *Proc) setState(state State) {
+// LogsSince returns the lines logged after the first n
+func (a *Proc) LogsSince(n int) []Line {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	if n >= len(a.logs) {
+		return nil
+	}
+
+	return slices.Clone(a.logs[n:])
+}
+
+func (a *Proc) setState(state State) {
+	a.mu.Lock()
 	a.state = state
-	a.update()
+	a.mu.Unlock()
+
+	a.publish(StateChanged{Proc: a.ID, State: state})
+}
+
+// swapState changes the state to state, only if it's one of from
+func (a *Proc) swapState(state State, from ...State) {
+	a.mu.Lock()
+	ok := slices.Contains(from, a.state)
+	if ok {
+		a.state = state
+	}
+	a.mu.Unlock()
+
+	if ok {
+		a.publish(StateChanged{Proc: a.ID, State: state})
+	}
 }
 
 func (a *Proc) State() State {
@@ -190,23 +613,49 @@ func (a *Proc) State() State {
 	return a.state
 }
 
-func (a *Proc) getCancel() *context.CancelFunc {
+// Stopped returns whether the process was stopped by the user
+func (a *Proc) Stopped() bool {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	return a.cancel
+	return a.stopped
 }
 
-func (a *Proc) setCancel(c *context.CancelFunc) {
+func (a *Proc) setStopped(stopped bool) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	a.cancel = c
+	a.stopped = stopped
 }
 
-func (a *Proc) update() {
-	select {
-	case a.onchange <- 1:
-	default:
+func (a *Proc) nextRun() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Output of the last run won't be continued
+	clear(a.cut)
+
+	a.runs++
+	return a.runs
+}
+
+func (a *Proc) setProcess(process *os.Process) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.process = process
+}
+
+func (a *Proc) getCancel() *context.CancelFunc {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.cancel
+}
+
+// publish sends e to the subscribers of the bus, it mustn't be called while holding the lock
+func (a *Proc) publish(e Event) {
+	if a.bus != nil {
+		a.bus.Publish(e)
 	}
 }
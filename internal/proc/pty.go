@@ -0,0 +1,38 @@
+package proc
+
+import (
+	"os"
+
+	"github.com/creack/pty"
+)
+
+// Resize sets the size of the proc's pseudo-terminal, used the next time it starts if it isn't running
+func (a *Proc) Resize(cols int, rows int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.size = &pty.Winsize{
+		Cols: uint16(max(cols, 1)),
+		Rows: uint16(max(rows, 1)),
+	}
+
+	if a.ptmx == nil {
+		return nil
+	}
+
+	return pty.Setsize(a.ptmx, a.size)
+}
+
+func (a *Proc) getPty() (*os.File, *pty.Winsize) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.ptmx, a.size
+}
+
+func (a *Proc) setPty(ptmx *os.File) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.ptmx = ptmx
+}
@@ -0,0 +1,56 @@
+package proc
+
+import "io"
+
+// maxLineLen is the longest a line can get before it's split
+const maxLineLen = 1024 * 1024
+
+// readLines reads r until it's closed, calling fn with every line.
+// A partial line is one that hasn't ended yet, or ended with a carriage return.
+// It's passed to fn as it arrives, and should be overwritten by whatever fn is called with next
+func readLines(r io.Reader, fn func(line []byte, partial bool)) {
+	buf := make([]byte, 32*1024)
+	line := []byte{}
+	cr := false
+
+	for {
+		n, err := r.Read(buf)
+
+		for _, b := range buf[:n] {
+			switch b {
+			case '\n':
+				fn(line, false)
+				line = line[:0]
+				cr = false
+
+			case '\r':
+				// Only known to be an overwrite once something other than \n follows
+				cr = true
+
+			default:
+				if cr {
+					fn(line, true)
+					line = line[:0]
+					cr = false
+				}
+
+				line = append(line, b)
+				if len(line) >= maxLineLen {
+					fn(line, false)
+					line = line[:0]
+				}
+			}
+		}
+
+		// Show what's arrived so far
+		if err != nil {
+			if len(line) > 0 {
+				fn(line, false)
+			}
+			return
+		}
+		if len(line) > 0 {
+			fn(line, true)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package proc
+
+import (
+	"slices"
+	"time"
+)
+
+type RestartPolicy string
+
+const (
+	// RestartNever never restarts the proc when it exits
+	RestartNever RestartPolicy = "never"
+	// RestartOnFailure restarts the proc when it exits with an error
+	RestartOnFailure RestartPolicy = "on-failure"
+	// RestartAlways restarts the proc whenever it exits
+	RestartAlways RestartPolicy = "always"
+	// RestartUnlessStopped restarts the proc whenever it exits, unless it was stopped by the user.
+	// A stopped proc also won't be started by file changes
+	RestartUnlessStopped RestartPolicy = "unless-stopped"
+)
+
+type Restart struct {
+	Policy RestartPolicy
+
+	// Backoff between restarts, growing from Initial by Multiplier up to Max
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+
+	// Give up after MaxRestarts restarts within Window, negative MaxRestarts never gives up
+	MaxRestarts int
+	Window      time.Duration
+}
+
+// withDefaults fills in unset values
+func (r Restart) withDefaults() Restart {
+	if r.Policy == "" {
+		r.Policy = RestartNever
+	}
+	if r.Initial <= 0 {
+		r.Initial = time.Second
+	}
+	if r.Max <= 0 {
+		r.Max = time.Second * 30
+	}
+	if r.Max < r.Initial {
+		r.Max = r.Initial
+	}
+	if r.Multiplier < 1 {
+		r.Multiplier = 2
+	}
+	if r.MaxRestarts == 0 {
+		r.MaxRestarts = 5
+	}
+	if r.Window <= 0 {
+		r.Window = time.Minute
+	}
+
+	return r
+}
+
+// shouldRestart checks whether a proc that exited with err should restart
+func (r Restart) shouldRestart(err error) bool {
+	switch r.Policy {
+	case RestartOnFailure:
+		return err != nil
+	case RestartAlways, RestartUnlessStopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff tracks the delay and recent restarts of a proc
+type backoff struct {
+	restart  Restart
+	delay    time.Duration
+	restarts []time.Time
+}
+
+func newBackoff(restart Restart) *backoff {
+	return &backoff{
+		restart:  restart,
+		delay:    restart.Initial,
+		restarts: []time.Time{},
+	}
+}
+
+// next returns how long to wait before restarting a run that lasted ran,
+// or false if the proc has restarted too many times within the window
+func (b *backoff) next(ran time.Duration) (time.Duration, bool) {
+	now := time.Now()
+
+	// A run that stayed up for longer than the max delay was healthy
+	if ran >= b.restart.Max {
+		b.delay = b.restart.Initial
+	}
+
+	b.restarts = slices.DeleteFunc(b.restarts, func(t time.Time) bool {
+		return now.Sub(t) > b.restart.Window
+	})
+	if b.restart.MaxRestarts > 0 && len(b.restarts) >= b.restart.MaxRestarts {
+		return 0, false
+	}
+	b.restarts = append(b.restarts, now)
+
+	delay := b.delay
+	b.delay = min(time.Duration(float64(b.delay)*b.restart.Multiplier), b.restart.Max)
+
+	return delay, true
+}
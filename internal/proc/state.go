@@ -7,13 +7,21 @@ const (
 	StateRunning
 	StateError
 	StateSuccess
+	StateCrashLoop
+	StateStarting
+	StateStopping
+	StateQueued
 )
 
 var stateName = map[State]string{
-	StateIdle:    "idle",
-	StateRunning: "running",
-	StateError:   "error",
-	StateSuccess: "success",
+	StateIdle:      "idle",
+	StateRunning:   "running",
+	StateError:     "error",
+	StateSuccess:   "success",
+	StateCrashLoop: "crash loop",
+	StateStarting:  "starting",
+	StateStopping:  "stopping",
+	StateQueued:    "queued",
 }
 
 func (as State) String() string {
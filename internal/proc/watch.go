@@ -67,6 +67,11 @@ func Watch(ctx context.Context, path string, procs []*Proc) error {
 					continue
 				}
 
+				// Leave procs the user stopped alone
+				if app.Restart.Policy == RestartUnlessStopped && app.Stopped() {
+					continue
+				}
+
 				// Rate limit calls
 				ok := rl.Check(app.Name)
 				if !ok {
@@ -77,12 +82,7 @@ func Watch(ctx context.Context, path string, procs []*Proc) error {
 					// Wait for rate limiter to complete
 					rl.Wait(app.Name)
 
-					// Wait for proc to stop
-					app.Stop()
-					app.Wait()
-
-					// Restart
-					go app.Start(ctx)
+					app.Change(ctx)
 				}()
 			}
 		}
@@ -1,13 +1,280 @@
 package settings
 
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// restarts are the supported restart policies
+var restarts = []string{"never", "on-failure", "always", "unless-stopped"}
+
+// splits are the supported layouts, vertical puts panes side by side and horizontal stacks them
+var splits = []string{"none", "horizontal", "vertical"}
+
+// MaxPanes is the most procs the layout can tile
+const MaxPanes = 4
+
+// kinds are the supported kinds of proc
+var kinds = []string{"task", "service"}
+
+// changePolicies are what can happen when a file changes while a proc is running
+var changePolicies = []string{"restart", "queue", "ignore"}
+
+// reserved can't be proc names, all is the log with the output of every proc
+var reserved = []string{"all"}
+
+// shells are the supported values for shell, none runs commands without a shell
+var shells = []string{"sh", "bash", "zsh", "fish", "none"}
+
 type proc struct {
-	Shell       string   `yaml:"onstart,omitempty"`
+	OnStart     string   `yaml:"onstart,omitempty"`
+	OnChange    string   `yaml:"onchange,omitempty"`
+	Shell       string   `yaml:"shell,omitempty"`
 	Cwd         string   `yaml:"cwd,omitempty"`
+	Color       string   `yaml:"color,omitempty"`
+	ForceColor  *bool    `yaml:"force_color,omitempty"`
+	Pty         bool     `yaml:"pty,omitempty"`
+	Stdin       bool     `yaml:"stdin,omitempty"`
 	Exts        []string `yaml:"exts,omitempty"`
 	AutoStart   bool     `yaml:"autostart,omitempty"`
 	AutoRestart bool     `yaml:"autorestart,omitempty"`
+	Kind        string   `yaml:"kind,omitempty"`
+	DependsOn   []string `yaml:"depends_on,omitempty"`
+
+	ResourceGroup string `yaml:"resource_group,omitempty"`
+
+	OnChangeWhileRunning string `yaml:"on_change_while_running,omitempty"`
+
+	Restart       string        `yaml:"restart,omitempty"`
+	Backoff       backoff       `yaml:"backoff,omitempty"`
+	MaxRestarts   int           `yaml:"max_restarts,omitempty"`
+	RestartWindow time.Duration `yaml:"restart_window,omitempty"`
+}
+
+type backoff struct {
+	Initial    time.Duration `yaml:"initial,omitempty"`
+	Max        time.Duration `yaml:"max,omitempty"`
+	Multiplier float64       `yaml:"multiplier,omitempty"`
+}
+
+// Layout is how procs are tiled in the TUI, saved on quit if it was changed
+type Layout struct {
+	Split string   `yaml:"split,omitempty"`
+	Panes []string `yaml:"panes,omitempty"`
+}
+
+// Equal returns whether both layouts tile the same procs the same way, no split is the same as none
+func (l Layout) Equal(o Layout) bool {
+	split := func(s string) string {
+		if s == "" {
+			return "none"
+		}
+		return s
+	}
+
+	return split(l.Split) == split(o.Split) && slices.Equal(l.Panes, o.Panes)
 }
 
 type Settings struct {
-	Procs map[string]proc `yaml:"procs"`
+	Shell      string          `yaml:"shell,omitempty"`
+	ForceColor bool            `yaml:"force_color,omitempty"`
+	Procs      map[string]proc `yaml:"procs"`
+	Layout     Layout          `yaml:"layout,omitempty"`
+
+	// Limits on how many tasks run at once, in total and in each resource group. Groups default to 1
+	MaxParallel    int            `yaml:"max_parallel,omitempty"`
+	ResourceGroups map[string]int `yaml:"resource_groups,omitempty"`
+
+	// Where the output of procs is written to, rotated once a file is bigger than log_max_size megabytes.
+	// log_max_files is how many rotated files are kept
+	LogDir      string `yaml:"log_dir,omitempty"`
+	LogMaxSize  int    `yaml:"log_max_size,omitempty"`
+	LogMaxFiles int    `yaml:"log_max_files,omitempty"`
+
+	// File is the config file, Dir is the directory containing it, every relative path is resolved against it
+	File string `yaml:"-"`
+	Dir  string `yaml:"-"`
+}
+
+// resolve makes every path in the settings absolute, relative to dir
+func (s *Settings) resolve(dir string) error {
+	s.Dir = dir
+
+	if s.LogDir != "" {
+		s.LogDir = resolvePath(dir, s.LogDir)
+	}
+
+	for name, p := range s.Procs {
+		p.Cwd = resolvePath(dir, p.Cwd)
+
+		info, err := os.Stat(p.Cwd)
+		if err != nil {
+			return fmt.Errorf("proc %s: cwd %s: %w", name, p.Cwd, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("proc %s: cwd %s is not a directory", name, p.Cwd)
+		}
+
+		s.Procs[name] = p
+	}
+
+	return nil
+}
+
+// validate checks for unsupported values and fills in inherited ones
+func (s *Settings) validate() error {
+	if s.Shell != "" && !slices.Contains(shells, s.Shell) {
+		return fmt.Errorf("unsupported shell %s", s.Shell)
+	}
+
+	if s.MaxParallel < 0 {
+		return fmt.Errorf("max_parallel can't be negative")
+	}
+	for group, limit := range s.ResourceGroups {
+		if limit < 1 {
+			return fmt.Errorf("resource group %s: limit must be at least 1", group)
+		}
+	}
+
+	switch {
+	case s.LogMaxSize < 0:
+		return fmt.Errorf("log_max_size can't be negative")
+	case s.LogMaxSize == 0:
+		s.LogMaxSize = 10
+	}
+	switch {
+	case s.LogMaxFiles < 0:
+		return fmt.Errorf("log_max_files can't be negative")
+	case s.LogMaxFiles == 0:
+		s.LogMaxFiles = 3
+	}
+
+	for name, p := range s.Procs {
+		if slices.Contains(reserved, name) {
+			return fmt.Errorf("proc %s: the name is reserved", name)
+		}
+		if p.Shell == "" {
+			p.Shell = s.Shell
+		}
+		if p.ForceColor == nil {
+			p.ForceColor = &s.ForceColor
+		}
+		if p.Shell != "" && !slices.Contains(shells, p.Shell) {
+			return fmt.Errorf("proc %s: unsupported shell %s", name, p.Shell)
+		}
+
+		// autorestart is an alias for always restarting
+		if p.Restart == "" && p.AutoRestart {
+			p.Restart = "always"
+		}
+		if p.Restart != "" && !slices.Contains(restarts, p.Restart) {
+			return fmt.Errorf("proc %s: unsupported restart policy %s", name, p.Restart)
+		}
+
+		// Procs that are always restarted never finish, so they're services unless told otherwise
+		if p.Kind == "" && (p.Restart == "always" || p.Restart == "unless-stopped") {
+			p.Kind = "service"
+		} else if p.Kind == "" {
+			p.Kind = "task"
+		}
+		if !slices.Contains(kinds, p.Kind) {
+			return fmt.Errorf("proc %s: unsupported kind %s", name, p.Kind)
+		}
+
+		if p.OnChangeWhileRunning == "" {
+			p.OnChangeWhileRunning = "restart"
+		}
+		if !slices.Contains(changePolicies, p.OnChangeWhileRunning) {
+			return fmt.Errorf("proc %s: unsupported on_change_while_running %s", name, p.OnChangeWhileRunning)
+		}
+
+		for _, dep := range p.DependsOn {
+			if _, ok := s.Procs[dep]; !ok || dep == name {
+				return fmt.Errorf("proc %s: depends on unknown proc %s", name, dep)
+			}
+		}
+
+		s.Procs[name] = p
+	}
+
+	// depends_on orders the tasks treli check runs, services aren't run by it or started in order
+	for _, name := range slices.Sorted(maps.Keys(s.Procs)) {
+		p := s.Procs[name]
+		if len(p.DependsOn) > 0 && p.Kind == "service" {
+			return fmt.Errorf("proc %s: depends_on is only used by treli check, which doesn't run services", name)
+		}
+		for _, dep := range p.DependsOn {
+			if s.Procs[dep].Kind == "service" {
+				return fmt.Errorf("proc %s: depends on service %s, which treli check doesn't run", name, dep)
+			}
+		}
+	}
+
+	if cycle := s.cycle(); cycle != nil {
+		return fmt.Errorf("procs depend on each other: %s", strings.Join(cycle, " -> "))
+	}
+
+	if s.Layout.Split != "" && !slices.Contains(splits, s.Layout.Split) {
+		return fmt.Errorf("layout: unsupported split %s", s.Layout.Split)
+	}
+
+	// The layout is saved by the TUI, so drop panes of procs that were since removed instead of failing
+	panes := []string{}
+	for _, name := range s.Layout.Panes {
+		if _, ok := s.Procs[name]; ok && !slices.Contains(panes, name) && len(panes) < MaxPanes {
+			panes = append(panes, name)
+		}
+	}
+	s.Layout.Panes = panes
+
+	return nil
+}
+
+// cycle returns procs that depend on each other in a loop, nil if there aren't any
+func (s *Settings) cycle() []string {
+	// Procs being visited are on the path, visited ones are known to be fine
+	path := []string{}
+	visited := map[string]bool{}
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		if i := slices.Index(path, name); i != -1 {
+			return append(slices.Clone(path[i:]), name)
+		}
+		if visited[name] {
+			return nil
+		}
+
+		path = append(path, name)
+		for _, dep := range s.Procs[name].DependsOn {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		visited[name] = true
+
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(s.Procs)) {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+func resolvePath(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(dir, path)
 }
@@ -0,0 +1,64 @@
+package settings
+
+import "testing"
+
+func TestValidateDependsOn(t *testing.T) {
+	tests := []struct {
+		name  string
+		procs map[string]proc
+		err   string
+	}{
+		{
+			name: "tasks",
+			procs: map[string]proc{
+				"lint": {DependsOn: []string{"gen"}},
+				"gen":  {},
+			},
+		},
+		{
+			name: "service",
+			procs: map[string]proc{
+				"app": {Kind: "service", DependsOn: []string{"gen"}},
+				"gen": {},
+			},
+			err: "proc app: depends_on is only used by treli check, which doesn't run services",
+		},
+		{
+			name: "on a service",
+			procs: map[string]proc{
+				"lint": {DependsOn: []string{"app"}},
+				"app":  {Restart: "always"},
+			},
+			err: "proc lint: depends on service app, which treli check doesn't run",
+		},
+		{
+			name: "unknown",
+			procs: map[string]proc{
+				"lint": {DependsOn: []string{"gen"}},
+			},
+			err: "proc lint: depends on unknown proc gen",
+		},
+		{
+			name: "cycle",
+			procs: map[string]proc{
+				"a": {DependsOn: []string{"b"}},
+				"b": {DependsOn: []string{"a"}},
+			},
+			err: "procs depend on each other: a -> b -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Settings{Procs: tt.procs}
+
+			err := s.validate()
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (err == nil || err.Error() != tt.err) {
+				t.Errorf("validate() = %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package settings
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// settle is how long the config file has to stay the same before it's read again, editors often write it more than once
+const settle = 200 * time.Millisecond
+
+// WatchYaml calls changed with the settings read again from the config file at path every time its contents change,
+// or with why they couldn't be read. Blocks until ctx is done
+func WatchYaml(ctx context.Context, path string, changed func(*Settings, error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// Editors replace the file instead of writing to it, so watch the directory
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	last, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(settle)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("could not watch for events")
+			}
+
+			if filepath.Clean(event.Name) == path {
+				timer.Reset(settle)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("could not watch for events")
+			}
+
+			return err
+
+		case <-timer.C:
+			sf, err := os.ReadFile(path)
+			if err != nil {
+				changed(nil, err)
+				continue
+			}
+			if bytes.Equal(sf, last) {
+				continue
+			}
+			last = sf
+
+			changed(GetYaml(path))
+		}
+	}
+}
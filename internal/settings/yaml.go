@@ -1,15 +1,24 @@
 package settings
 
 import (
+	"bytes"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 
 	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/parser"
 )
 
+var yamlName = regexp.MustCompile(`^(\.)?treli.y(a)?ml$`)
+
 func GetYaml(path string) (*Settings, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
 	// Read settings file
 	sf, err := os.ReadFile(path)
 	if err != nil {
@@ -22,14 +31,57 @@ func GetYaml(path string) (*Settings, error) {
 		return nil, err
 	}
 
+	// Make sure settings are valid
+	if err := settings.validate(); err != nil {
+		return nil, err
+	}
+
+	// Resolve paths relative to the settings file
+	settings.File = path
+	if err := settings.resolve(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
 	return &settings, nil
 }
 
+// FindYaml looks for a config file in path and its parents up to the root of the git repo,
+// or the home directory outside of one, falling back to searching the directories below path
 func FindYaml(path string) (string, error) {
-	r, _ := regexp.Compile(`^(\.)?treli.y(a)?ml$`)
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	home, _ := os.UserHomeDir()
 
+	// Search upwards
+	for dir := path; ; dir = filepath.Dir(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			break
+		}
+
+		repo := false
+		for _, entry := range entries {
+			if !entry.IsDir() && yamlName.MatchString(entry.Name()) {
+				return filepath.Join(dir, entry.Name()), nil
+			}
+
+			// .git is a file in worktrees and submodules
+			if entry.Name() == ".git" {
+				repo = true
+			}
+		}
+
+		// Configs above the project belong to something else
+		if repo || filepath.Dir(dir) == dir || filepath.Dir(dir) == home {
+			break
+		}
+	}
+
+	// Search downwards
 	var file string
-	err := filepath.WalkDir(path, func(p string, info os.DirEntry, err error) error {
+	err = filepath.WalkDir(path, func(p string, info os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -37,7 +89,7 @@ func FindYaml(path string) (string, error) {
 			return nil
 		}
 
-		if r.MatchString(info.Name()) {
+		if yamlName.MatchString(info.Name()) {
 			file = p
 			return fs.SkipAll
 		}
@@ -66,3 +118,48 @@ func CreateYaml(path string, settings *Settings) error {
 
 	return nil
 }
+
+// SaveLayout writes the layout to the config file at path, leaving the rest of the file, and its comments, as they are
+func SaveLayout(path string, layout Layout) error {
+	sf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := parser.ParseBytes(sf, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	// Replace the existing layout, or add one
+	lp, err := yaml.PathString("$.layout")
+	if err != nil {
+		return err
+	}
+
+	if _, err := lp.FilterFile(file); err == nil {
+		value, err := yaml.Marshal(layout)
+		if err != nil {
+			return err
+		}
+
+		if err := lp.ReplaceWithReader(file, bytes.NewReader(value)); err != nil {
+			return err
+		}
+	} else {
+		value, err := yaml.Marshal(map[string]Layout{"layout": layout})
+		if err != nil {
+			return err
+		}
+
+		root, err := yaml.PathString("$")
+		if err != nil {
+			return err
+		}
+		if err := root.MergeFromReader(file, bytes.NewReader(value)); err != nil {
+			return err
+		}
+	}
+
+	return os.WriteFile(path, []byte(file.String()), 0644)
+}
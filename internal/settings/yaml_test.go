@@ -0,0 +1,166 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tree creates files in dir, paths ending in / are directories
+func tree(t *testing.T, dir string, paths ...string) {
+	t.Helper()
+
+	for _, p := range paths {
+		path := filepath.Join(dir, p)
+		if strings.HasSuffix(p, "/") {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("procs: {}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindYaml(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		from  string
+		want  string
+	}{
+		{
+			name:  "in dir",
+			paths: []string{"repo/.git/", "repo/treli.yaml"},
+			from:  "repo",
+			want:  "repo/treli.yaml",
+		},
+		{
+			name:  "hidden yml",
+			paths: []string{"repo/.git/", "repo/.treli.yml"},
+			from:  "repo",
+			want:  "repo/.treli.yml",
+		},
+		{
+			name:  "in parent",
+			paths: []string{"repo/.git/", "repo/treli.yaml", "repo/client/src/"},
+			from:  "repo/client/src",
+			want:  "repo/treli.yaml",
+		},
+		{
+			name:  "closest parent",
+			paths: []string{"repo/.git/", "repo/treli.yaml", "repo/client/treli.yaml", "repo/client/src/"},
+			from:  "repo/client/src",
+			want:  "repo/client/treli.yaml",
+		},
+		{
+			name:  "not above git root",
+			paths: []string{"treli.yaml", "repo/.git/", "repo/client/"},
+			from:  "repo/client",
+			want:  "",
+		},
+		{
+			name:  "not above git worktree",
+			paths: []string{"treli.yaml", "repo/.git", "repo/client/"},
+			from:  "repo/client",
+			want:  "",
+		},
+		{
+			name:  "not in home",
+			paths: []string{"home/treli.yaml", "home/project/client/"},
+			from:  "home/project/client",
+			want:  "",
+		},
+		{
+			name:  "below",
+			paths: []string{"repo/.git/", "repo/tools/treli.yaml"},
+			from:  "repo",
+			want:  "repo/tools/treli.yaml",
+		},
+		{
+			name:  "above before below",
+			paths: []string{"repo/.git/", "repo/treli.yaml", "repo/client/tools/treli.yaml"},
+			from:  "repo/client",
+			want:  "repo/treli.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tree(t, dir, tt.paths...)
+			t.Setenv("HOME", filepath.Join(dir, "home"))
+
+			got, err := FindYaml(filepath.Join(dir, tt.from))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := ""
+			if tt.want != "" {
+				want = filepath.Join(dir, tt.want)
+			}
+			if got != want {
+				t.Errorf("FindYaml() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGetYamlNested(t *testing.T) {
+	tests := []struct {
+		name    string
+		cwd     string
+		from    string
+		want    string
+		wantErr bool
+	}{
+		{name: "relative from root", cwd: "client", from: ".", want: "client"},
+		{name: "relative from nested dir", cwd: "client", from: "client/src", want: "client"},
+		{name: "relative from sibling", cwd: "./client", from: "server", want: "client"},
+		{name: "empty is config dir", cwd: "", from: "client/src", want: "."},
+		{name: "missing", cwd: "web", from: "client", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tree(t, dir, ".git/", "client/src/", "server/")
+
+			config := "procs:\n  app:\n    onstart: echo\n    cwd: \"" + tt.cwd + "\"\n"
+			if err := os.WriteFile(filepath.Join(dir, "treli.yaml"), []byte(config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			path, err := FindYaml(filepath.Join(dir, tt.from))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := GetYaml(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GetYaml() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if s.Dir != dir {
+				t.Errorf("Dir = %q, want %q", s.Dir, dir)
+			}
+			if got, want := s.Procs["app"].Cwd, filepath.Join(dir, tt.want); got != want {
+				t.Errorf("Cwd = %q, want %q", got, want)
+			}
+		})
+	}
+}
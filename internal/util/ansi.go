@@ -0,0 +1,108 @@
+package util
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mattn/go-runewidth"
+)
+
+const tabWidth = 8
+
+// SanitizeANSI keeps SGR color sequences, but removes every other escape sequence and control character,
+// like cursor movement and erasing, that would break the layout. Tabs are expanded to spaces
+func SanitizeANSI(s string) string {
+	if !hasControl(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	col := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\x1b':
+			n, keep := escapeLen(s[i:])
+			if keep {
+				sb.WriteString(s[i : i+n])
+			}
+			i += n
+
+		case c == '\t':
+			spaces := tabWidth - col%tabWidth
+			sb.WriteString(strings.Repeat(" ", spaces))
+			col += spaces
+			i++
+
+		case c < 0x20 || c == 0x7f:
+			i++
+
+		default:
+			r, n := utf8.DecodeRuneInString(s[i:])
+			sb.WriteString(s[i : i+n])
+			col += runewidth.RuneWidth(r)
+			i += n
+		}
+	}
+
+	return sb.String()
+}
+
+// StripANSI removes every escape sequence
+func StripANSI(s string) string {
+	return ansi.Strip(s)
+}
+
+func hasControl(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeLen returns the length of the escape sequence at the start of s, and whether it's an SGR sequence
+func escapeLen(s string) (int, bool) {
+	if len(s) < 2 {
+		return len(s), false
+	}
+
+	switch s[1] {
+	// Control sequence, parameters and intermediates followed by a final byte
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1, s[i] == 'm' && !strings.ContainsAny(s[2:i], "<=>?")
+			}
+			if s[i] < 0x20 || s[i] > 0x7e {
+				return i, false
+			}
+		}
+		return len(s), false
+
+	// Strings, terminated by BEL or ST
+	case ']', 'P', 'X', '^', '_':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1, false
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2, false
+			}
+		}
+		return len(s), false
+
+	// Everything else, intermediates followed by a final byte
+	default:
+		for i := 1; i < len(s); i++ {
+			if s[i] < 0x20 || s[i] > 0x2f {
+				return i + 1, false
+			}
+		}
+		return len(s), false
+	}
+}
@@ -2,12 +2,21 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"maps"
 	"os"
 	"os/signal"
+	"runtime"
+	"slices"
 	"syscall"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+	"github.com/spotdemo4/treli/internal/control"
+	"github.com/spotdemo4/treli/internal/ctl"
+	"github.com/spotdemo4/treli/internal/headless"
+	"github.com/spotdemo4/treli/internal/logs"
 	"github.com/spotdemo4/treli/internal/model"
 	"github.com/spotdemo4/treli/internal/proc"
 	"github.com/spotdemo4/treli/internal/settings"
@@ -15,6 +24,63 @@ import (
 )
 
 func main() {
+	noTUI := flag.Bool("no-tui", false, "stream output with prefixes instead of showing the TUI, the default without a terminal")
+	exit := flag.Bool("exit", false, "without the TUI, exit once the autostart tasks are done instead of running until stopped, for CI")
+	flag.Parse()
+
+	cmd := flag.Arg(0)
+	args := []string{}
+
+	// treli check runs every task once
+	checkFlags := flag.NewFlagSet("check", flag.ExitOnError)
+	jobs := checkFlags.Int("j", runtime.NumCPU(), "how many tasks to run at once")
+
+	// treli logs <proc> [-f] prints the output of the running treli, or what was written to log_dir
+	logsFlags := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := logsFlags.Bool("f", false, "keep printing new output")
+	logsFlags.Usage = func() {
+		fmt.Fprintf(logsFlags.Output(), "Usage: treli logs <proc|%s> [-f]\n", logs.All)
+		logsFlags.PrintDefaults()
+	}
+
+	// treli start|stop|restart <proc>... [--all] and treli status control the running treli
+	controlFlags := flag.NewFlagSet(cmd, flag.ExitOnError)
+	all := controlFlags.Bool("all", false, "every proc")
+	controlFlags.Usage = func() {
+		fmt.Fprintf(controlFlags.Output(), "Usage: treli %s <proc>... [--all]\n", cmd)
+		controlFlags.PrintDefaults()
+	}
+
+	switch cmd {
+	case "":
+	case "check":
+		args = parseArgs(checkFlags, flag.Args()[1:])
+
+	case "logs":
+		args = parseArgs(logsFlags, flag.Args()[1:])
+		if len(args) != 1 {
+			logsFlags.Usage()
+			os.Exit(2)
+		}
+
+	case "start", "stop", "restart":
+		args = parseArgs(controlFlags, flag.Args()[1:])
+		if len(args) == 0 && !*all {
+			controlFlags.Usage()
+			os.Exit(2)
+		}
+
+	case "status":
+		if flag.NArg() > 1 {
+			fmt.Println("Usage: treli status")
+			os.Exit(2)
+		}
+
+	default:
+		fmt.Printf("Unknown command %s\n", cmd)
+		os.Exit(2)
+	}
+
 	// Get current path
 	path := os.Getenv("DIR")
 	if path == "" {
@@ -26,7 +92,7 @@ func main() {
 		}
 	}
 
-	// Get shell
+	// Get default shell
 	sh, ok := shell.CurrentUserShell()
 	if !ok {
 		sh = shell.DefaultShell()
@@ -52,46 +118,121 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Drive the treli running for this config through its control socket, without starting anything
+	switch cmd {
+	case "logs", "start", "stop", "restart", "status":
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		client := control.NewClient(control.Socket(s.Dir))
+
+		code := 0
+		switch cmd {
+		case "logs":
+			if _, ok := s.Procs[args[0]]; !ok && args[0] != logs.All {
+				fmt.Printf("Proc %s not found\n", args[0])
+				os.Exit(1)
+			}
+			code = ctl.Logs(ctx, client, s.LogDir, args[0], *follow, os.Stdout)
+
+		case "status":
+			code = ctl.Status(ctx, client, os.Stdout)
+
+		default:
+			code = ctl.Control(ctx, client, ctl.Action(cmd), args, *all, os.Stdout)
+		}
+
+		stop()
+		os.Exit(code)
+	}
+
 	// If there's no apps we can't do anything, so just exit
 	if len(s.Procs) == 0 {
 		fmt.Println("No procs found")
 		os.Exit(0)
 	}
 
-	// Create msg channel and context
-	onchange := make(chan int, 100)
+	// Create event bus and context
+	bus := proc.NewBus()
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// Create limits
+	var parallel *proc.Semaphore
+	if s.MaxParallel > 0 {
+		parallel = proc.NewSemaphore(s.MaxParallel)
+	}
+	groups := map[string]*proc.Semaphore{}
+
 	// Create apps
 	procs := []*proc.Proc{}
-	for name, p := range s.Procs {
-		proc, err := proc.New(
-			name,
-			p.Exts,
-			p.AutoStart,
-			p.AutoRestart,
-			p.Shell,
-			p.Cwd,
-			sh,
-			onchange,
-		)
-		if err != nil {
-			fmt.Printf("Cannot load proc %s: %s\n", name, err.Error())
-			os.Exit(1)
+	for _, name := range slices.Sorted(maps.Keys(s.Procs)) {
+		p := s.Procs[name]
+
+		psh := sh
+		if p.Shell != "" {
+			psh = p.Shell
 		}
 
-		procs = append(procs, proc)
-	}
+		// Tell procs to output color even though they aren't writing to a terminal
+		env := []string{}
+		if *p.ForceColor {
+			env = append(env, "FORCE_COLOR=1", "CLICOLOR_FORCE=1")
+		}
 
-	// Start apps
-	for _, proc := range procs {
-		if proc.AutoStart {
-			go proc.Start(ctx)
+		// Services run until they're stopped, they'd hold on to a slot forever
+		limits := []*proc.Semaphore{}
+		if p.Kind == string(proc.KindTask) {
+			if p.ResourceGroup != "" {
+				if _, ok := groups[p.ResourceGroup]; !ok {
+					groups[p.ResourceGroup] = proc.NewSemaphore(max(s.ResourceGroups[p.ResourceGroup], 1))
+				}
+				limits = append(limits, groups[p.ResourceGroup])
+			}
+			if parallel != nil {
+				limits = append(limits, parallel)
+			}
 		}
+
+		np := proc.New(name, proc.Options{
+			Color:     p.Color,
+			Exts:      p.Exts,
+			AutoStart: p.AutoStart,
+			Restart: proc.Restart{
+				Policy:      proc.RestartPolicy(p.Restart),
+				Initial:     p.Backoff.Initial,
+				Max:         p.Backoff.Max,
+				Multiplier:  p.Backoff.Multiplier,
+				MaxRestarts: p.MaxRestarts,
+				Window:      p.RestartWindow,
+			},
+			Kind:                 proc.Kind(p.Kind),
+			DependsOn:            p.DependsOn,
+			OnChangeWhileRunning: proc.ChangePolicy(p.OnChangeWhileRunning),
+			OnStart:              p.OnStart,
+			OnChange:             p.OnChange,
+			Dir:                  p.Cwd,
+			Shell:                psh,
+			Env:                  env,
+			Pty:                  p.Pty,
+			Stdin:                p.Stdin,
+			Limits:               limits,
+		}, bus)
+
+		procs = append(procs, np)
 	}
 
-	// Start watching
-	go proc.Watch(ctx, path, procs)
+	// Write output to log_dir
+	closeLogs := func() {}
+	if s.LogDir != "" {
+		w, err := logs.NewWriter(s.LogDir, int64(s.LogMaxSize)*1024*1024, s.LogMaxFiles, procs, bus)
+		if err != nil {
+			fmt.Printf("Could not write logs: %v\n", err)
+			os.Exit(1)
+		}
+		closeLogs = func() {
+			if err := w.Close(); err != nil {
+				fmt.Printf("Could not write logs: %v\n", err)
+			}
+		}
+	}
 
 	// Gracefully shutdown on SIGINT or SIGTERM
 	sigs := make(chan os.Signal, 1)
@@ -104,16 +245,81 @@ func main() {
 		for _, p := range procs {
 			p.Wait()
 		}
-		close(onchange)
+		bus.Close()
 	}()
 
+	// Run tasks without watching for changes
+	if cmd == "check" {
+		code := headless.Check(ctx, procs, bus, os.Stdout, *jobs)
+		closeLogs()
+		os.Exit(code)
+	}
+
+	// Start watching
+	go proc.Watch(ctx, s.Dir, procs)
+	go settings.WatchYaml(ctx, s.File, func(_ *settings.Settings, err error) {
+		bus.Publish(proc.ConfigReloaded{File: s.File, Err: err})
+	})
+
+	// Let scripts and editors control procs, treli works without it
+	stopControl, err := control.NewServer(ctx, procs, bus).Listen(control.Socket(s.Dir))
+	if err != nil {
+		fmt.Printf("Could not start control server, treli start, stop, restart and status won't work: %v\n", err)
+		stopControl = func() {}
+	}
+
+	// Stream output when there's no terminal for the TUI
+	if *noTUI || !term.IsTerminal(os.Stdout.Fd()) {
+		// Keep running for file changes, or for someone watching over SSH.
+		// Otherwise there's nothing left to do once the tasks are done
+		watching := slices.ContainsFunc(procs, func(p *proc.Proc) bool { return len(p.Exts) > 0 })
+		interactive := term.IsTerminal(os.Stdin.Fd()) || term.IsTerminal(os.Stdout.Fd())
+
+		code := headless.Run(ctx, procs, bus, os.Stdout, *exit || (!watching && !interactive))
+		stopControl()
+		closeLogs()
+		os.Exit(code)
+	}
+
+	// Start apps
+	for _, p := range procs {
+		if p.AutoStart {
+			go p.Start(ctx, proc.TriggerAutostart)
+		}
+	}
+
 	// Start tea
+	runner := model.NewRunner(ctx, procs, bus, s.Layout, s.Dir)
 	p := tea.NewProgram(
-		model.NewRunner(apps, msgs),
+		runner,
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Error running tea: %v\n", err)
 	}
+
+	// Save the layout to the config file once on quit, and only if it was changed
+	if layout := runner.Layout(); !layout.Equal(s.Layout) {
+		if err := settings.SaveLayout(s.File, layout); err != nil {
+			fmt.Printf("Error saving layout: %v\n", err)
+		}
+	}
+	stopControl()
+	closeLogs()
+}
+
+// parseArgs parses the flags of a subcommand, which can come before, between or after its arguments.
+// Returns the arguments
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	rest := []string{}
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			return rest
+		}
+
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
 }
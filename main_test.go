@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args   []string
+		want   []string
+		follow bool
+		all    bool
+		jobs   int
+	}{
+		{args: []string{}, want: []string{}},
+		{args: []string{"api"}, want: []string{"api"}},
+		{args: []string{"api", "-f"}, want: []string{"api"}, follow: true},
+		{args: []string{"-f", "api"}, want: []string{"api"}, follow: true},
+		{args: []string{"--f", "api"}, want: []string{"api"}, follow: true},
+		{args: []string{"api", "web", "--all"}, want: []string{"api", "web"}, all: true},
+		{args: []string{"api", "--all", "web"}, want: []string{"api", "web"}, all: true},
+		{args: []string{"--all", "api", "-f", "web"}, want: []string{"api", "web"}, follow: true, all: true},
+		{args: []string{"-j", "2", "api"}, want: []string{"api"}, jobs: 2},
+		{args: []string{"api", "-j", "2", "web"}, want: []string{"api", "web"}, jobs: 2},
+		{args: []string{"api", "-j=3"}, want: []string{"api"}, jobs: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			follow := fs.Bool("f", false, "")
+			all := fs.Bool("all", false, "")
+			jobs := fs.Int("j", 0, "")
+
+			if got := parseArgs(fs, tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("parseArgs() = %q, want %q", got, tt.want)
+			}
+			if *follow != tt.follow || *all != tt.all || *jobs != tt.jobs {
+				t.Errorf("flags = -f %v -all %v -j %d, want -f %v -all %v -j %d", *follow, *all, *jobs, tt.follow, tt.all, tt.jobs)
+			}
+		})
+	}
+}